@@ -0,0 +1,31 @@
+// Command gateway-stub serves the fake payment gateway of package gatewaystub, to run the service
+// locally with PROCESSOR_TYPE=gateway.
+//
+//	GATEWAY_STUB_PORT     port to listen on, default 9090
+//	GATEWAY_STUB_BEHAVIOR default behavior of every call: approve, decline, pending, timeout or server_error
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"payment-service/internal/processor/gatewaystub"
+)
+
+func main() {
+	port := os.Getenv("GATEWAY_STUB_PORT")
+	if port == "" {
+		port = "9090"
+	}
+
+	stub := gatewaystub.New()
+	if behavior := os.Getenv("GATEWAY_STUB_BEHAVIOR"); behavior != "" {
+		stub.SetDefault(gatewaystub.Behavior(behavior))
+	}
+
+	log.Printf("Gateway stub listening on :%s", port)
+	if err := http.ListenAndServe(":"+port, stub.Handler()); err != nil {
+		log.Fatalf("Failed to start gateway stub: %v", err)
+	}
+}
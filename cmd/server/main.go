@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"payment-service/internal/config"
 	"payment-service/internal/database"
+	"payment-service/internal/fx"
 	"payment-service/internal/handlers"
+	"payment-service/internal/outbox"
+	"payment-service/internal/processor"
+	"payment-service/internal/redis"
 	"payment-service/internal/repositories"
 	"payment-service/internal/routes"
 	"payment-service/internal/services"
 	"payment-service/internal/validator"
+	"payment-service/internal/worker"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
@@ -32,17 +39,183 @@ func main() {
 	paymentRepo := repositories.NewPaymentRepository(db)
 	walletRepo := repositories.NewWalletRepository(db)
 	userRepo := repositories.NewUserRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	lockFenceRepo := repositories.NewLockFenceRepository(db)
+	paymentJobRepo := repositories.NewPaymentJobRepository(db)
+	recoveryAuditRepo := repositories.NewPaymentRecoveryAuditRepository(db)
+	processorEventRepo := repositories.NewProcessorEventRepository(db)
+	webhookEndpointRepo := repositories.NewWebhookEndpointRepository(db)
+	webhookDeliveryRepo := repositories.NewWebhookDeliveryRepository(db)
+	outboxEventRepo := repositories.NewOutboxEventRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	refundRepo := repositories.NewRefundRepository(db)
+	depositRepo := repositories.NewDepositRepository(db)
+	transferRepo := repositories.NewTransferRepository(db)
+	fxQuoteRepo := repositories.NewFxQuoteRepository(db)
+
+	// Initialize lock, Redis is required when running more than one replica
+	lockOptions := redis.LockOptions{TTL: cfg.Lock.TTL, Wait: cfg.Lock.Wait}
+	var locker redis.Locker
+	if cfg.Redis.Enabled {
+		redisLocker := redis.NewRedisLocker(redis.NewClient(cfg.Redis), lockOptions)
+		if err := redisLocker.Ping(context.Background()); err != nil {
+			log.Fatalf("Failed to initialize redis: %v", err)
+		}
+		// tokens handed out by Redis must stay above those already stored, whichever locker wrote them
+		maxFence, err := lockFenceRepo.MaxFenceToken()
+		if err != nil {
+			log.Fatalf("Failed to read the fencing tokens: %v", err)
+		}
+		if err := redisLocker.SeedFence(context.Background(), maxFence); err != nil {
+			log.Fatalf("Failed to initialize redis: %v", err)
+		}
+		locker = redisLocker
+	} else {
+		lockManager := redis.NewLockManager(lockOptions)
+		lockManager.StartReaper(context.Background(), cfg.Lock.ReaperInterval)
+		locker = lockManager
+	}
+
+	// Initialize payment processor
+	var paymentProcessor processor.PaymentProcessor
+	switch cfg.Processor.Type {
+	case "gateway":
+		gatewayOptions := processor.DefaultGatewayOptions(cfg.Processor.GatewayBaseURL, cfg.Processor.GatewayAPIKey)
+		gatewayOptions.RequestTimeout = cfg.Processor.GatewayTimeout
+		gatewayOptions.MaxRetries = cfg.Processor.GatewayMaxRetries
+		gatewayOptions.PollTimeout = cfg.Processor.GatewayPollTimeout
+		paymentProcessor = processor.NewGatewayProcessor(gatewayOptions)
+	case "simulator":
+		paymentProcessor = processor.NewSimulator(processor.SimulatorOptions{
+			Seed:        cfg.Processor.SimulatorSeed,
+			SuccessRate: cfg.Processor.SimulatorSuccessRate,
+			MinLatency:  cfg.Processor.SimulatorMinLatency,
+			MaxLatency:  cfg.Processor.SimulatorMaxLatency,
+		})
+	default:
+		log.Fatalf("Unknown payment processor type: %s", cfg.Processor.Type)
+	}
+
+	// Initialize exchange rates
+	var rateProvider fx.RateProvider
+	switch cfg.Fx.Provider {
+	case "static":
+		rates, err := fx.ParseRates(cfg.Fx.StaticRates)
+		if err != nil {
+			log.Fatalf("Failed to parse FX rates: %v", err)
+		}
+		rateProvider = fx.NewStaticProvider(rates)
+	case "file":
+		fileProvider, err := fx.NewFileProvider(cfg.Fx.RatesFile)
+		if err != nil {
+			log.Fatalf("Failed to load FX rates file: %v", err)
+		}
+		rateProvider = fileProvider
+	default:
+		log.Fatalf("Unknown FX provider: %s", cfg.Fx.Provider)
+	}
+	fxOptions := fx.DefaultOptions()
+	fxOptions.Spread = decimal.NewFromFloat(cfg.Fx.Spread)
+	fxOptions.QuoteTTL = cfg.Fx.QuoteTTL
 
 	// Initialize services
-	paymentService := services.NewPaymentService(db, paymentRepo, walletRepo)
-	userService := services.NewUserService(db, userRepo, walletRepo)
+	fxService := services.NewFxService(fxOptions, fxQuoteRepo, rateProvider)
+	webhookService := services.NewWebhookService(webhookEndpointRepo, webhookDeliveryRepo)
+	paymentService := services.NewPaymentService(db, paymentRepo, walletRepo, lockFenceRepo, paymentJobRepo, processorEventRepo, outboxEventRepo, ledgerRepo, locker, paymentProcessor, fxService)
+	overdraftLimit := decimal.NewFromFloat(cfg.Wallet.OverdraftLimit)
+	if overdraftLimit.IsNegative() {
+		log.Fatalf("Invalid wallet overdraft limit: %s", overdraftLimit)
+	}
+	userService := services.NewUserService(db, userRepo, walletRepo, ledgerRepo, paymentRepo, overdraftLimit)
+	ledgerService := services.NewLedgerService(db, ledgerRepo, walletRepo)
+	refundService := services.NewRefundService(db, refundRepo, paymentRepo, walletRepo, outboxEventRepo, ledgerRepo)
+	depositService := services.NewDepositService(db, depositRepo, walletRepo, lockFenceRepo, ledgerRepo, locker)
+	transferService := services.NewTransferService(db, transferRepo, walletRepo, lockFenceRepo, ledgerRepo, locker)
+
+	// Post the history of wallets created before the ledger existed, before any worker posts to them and
+	// before they are checked against it, a wallet with a posting is never backfilled
+	backfilled, err := ledgerService.BackfillWallets()
+	if err != nil {
+		log.Fatalf("Failed to backfill the ledger: %v", err)
+	}
+	if backfilled > 0 {
+		log.Printf("Backfilled the ledger of %d wallets", backfilled)
+	}
+
+	// Start payment workers
+	paymentWorkers := worker.NewPaymentWorkerPool(worker.Options{
+		Concurrency:       cfg.Worker.Concurrency,
+		PollInterval:      cfg.Worker.PollInterval,
+		MaxAttempts:       cfg.Worker.MaxAttempts,
+		BaseBackoff:       cfg.Worker.BaseBackoff,
+		MaxBackoff:        cfg.Worker.MaxBackoff,
+		VisibilityTimeout: cfg.Worker.VisibilityTimeout,
+	}, paymentJobRepo, paymentService)
+	paymentWorkers.Start(context.Background())
+
+	// Start recovery of stuck pending payments, runs now and then periodically
+	recoveryOptions := worker.DefaultRecoveryOptions()
+	recoveryOptions.Interval = cfg.Recovery.Interval
+	recoveryOptions.PendingThreshold = cfg.Recovery.PendingThreshold
+	recoveryOptions.FailAfter = cfg.Recovery.FailAfter
+	recoverySweeper := worker.NewRecoverySweeper(recoveryOptions, paymentRepo, paymentJobRepo, recoveryAuditRepo, paymentService)
+	recoverySweeper.Start(context.Background())
+
+	// Check wallet balances against the ledger, runs now and then periodically
+	worker.StartReconciliation(context.Background(), cfg.Recovery.ReconciliationInterval, ledgerService)
+
+	// Start the outbox relay, it feeds the merchant webhooks and the configured sinks
+	sinks := outbox.MultiSink{webhookService}
+	for _, name := range cfg.Outbox.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, outbox.NewLogSink())
+		case "http":
+			sinks = append(sinks, outbox.NewHTTPSink(cfg.Outbox.HTTPURL, cfg.Outbox.HTTPSecret, cfg.Webhook.RequestTimeout))
+		case "file":
+			fileSink, err := outbox.NewFileSink(cfg.Outbox.FilePath)
+			if err != nil {
+				log.Fatalf("Failed to open outbox file sink: %v", err)
+			}
+			sinks = append(sinks, fileSink)
+		default:
+			log.Fatalf("Unknown outbox sink: %s", name)
+		}
+	}
+	outboxOptions := worker.DefaultOutboxOptions()
+	outboxOptions.PollInterval = cfg.Outbox.PollInterval
+	outboxRelay := worker.NewOutboxRelay(outboxOptions, outboxEventRepo, sinks)
+	outboxRelay.Start(context.Background())
+
+	// Start merchant webhook deliveries
+	webhookOptions := worker.DefaultWebhookOptions()
+	webhookOptions.Concurrency = cfg.Webhook.Concurrency
+	webhookOptions.MaxAttempts = cfg.Webhook.MaxAttempts
+	webhookOptions.BaseBackoff = cfg.Webhook.BaseBackoff
+	webhookOptions.MaxBackoff = cfg.Webhook.MaxBackoff
+	webhookOptions.RequestTimeout = cfg.Webhook.RequestTimeout
+	webhookDispatcher := worker.NewWebhookDispatcher(webhookOptions, webhookEndpointRepo, webhookDeliveryRepo)
+	webhookDispatcher.Start(context.Background())
 
 	// Initialize controllers
 	paymentHandler := handlers.NewPaymentHandler(paymentService, userService)
 	userHandler := handlers.NewUserHandler(userService)
+	metricsHandler := handlers.NewMetricsHandler(locker)
+	webhookHandler := handlers.NewWebhookHandler(paymentService, cfg.Processor.WebhookSecret, cfg.Processor.WebhookTolerance)
+	webhookEndpointHandler := handlers.NewWebhookEndpointHandler(webhookService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	refundHandler := handlers.NewRefundHandler(refundService)
+	depositHandler := handlers.NewDepositHandler(depositService)
+	transferHandler := handlers.NewTransferHandler(transferService)
+	fxHandler := handlers.NewFxHandler(fxService)
+
+	// An in-progress Idempotency-Key record is only released once its request can no longer be running:
+	// it waited for its lock for at most LOCK_WAIT, and its writes are fenced off once the lock expired after LOCK_TTL
+	idempotencyOptions := routes.DefaultIdempotencyOptions()
+	idempotencyOptions.StaleAfter = max(cfg.Idempotency.StaleAfter, cfg.Lock.Wait+cfg.Lock.TTL)
 
 	// Setup routes
-	router := routes.RegisterRoutes(paymentHandler, userHandler)
+	router := routes.RegisterRoutes(paymentHandler, userHandler, metricsHandler, webhookHandler, webhookEndpointHandler, ledgerHandler, refundHandler, depositHandler, transferHandler, fxHandler, idempotencyRepo, idempotencyOptions)
 
 	// Register validators
 	validator.RegisterValidators()
@@ -3,15 +3,27 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	App      AppConfig
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	App         AppConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	Lock        LockConfig
+	Wallet      WalletConfig
+	Idempotency IdempotencyConfig
+	Worker      WorkerConfig
+	Recovery    RecoveryConfig
+	Processor   ProcessorConfig
+	Webhook     WebhookConfig
+	Outbox      OutboxConfig
+	Fx          FxConfig
 }
 
 type ServerConfig struct {
@@ -29,11 +41,86 @@ type DatabaseConfig struct {
 }
 
 type RedisConfig struct {
+	Enabled  bool // use Redis for distributed locks instead of the in-memory LockManager
 	Host     string
 	Port     string
 	Password string
 }
 
+type LockConfig struct {
+	TTL            time.Duration // a lock is released automatically after the TTL
+	Wait           time.Duration // how long a request waits for a lock held by another request
+	ReaperInterval time.Duration // how often the in-memory LockManager evicts expired locks
+}
+
+type WalletConfig struct {
+	OverdraftLimit float64 // how far the balance of a new wallet may go below zero, in the wallet's currency
+}
+
+type IdempotencyConfig struct {
+	StaleAfter time.Duration // an in-progress Idempotency-Key record older than this is released, never less than LOCK_WAIT + LOCK_TTL
+}
+
+type WorkerConfig struct {
+	Concurrency       int
+	PollInterval      time.Duration
+	MaxAttempts       int
+	BaseBackoff       time.Duration
+	MaxBackoff        time.Duration
+	VisibilityTimeout time.Duration
+}
+
+type RecoveryConfig struct {
+	Interval         time.Duration
+	PendingThreshold time.Duration
+	FailAfter        time.Duration
+
+	ReconciliationInterval time.Duration // how often wallets are checked against the ledger
+}
+
+// ProcessorConfig configures the payment processor, the simulator or the HTTP gateway adapter
+type ProcessorConfig struct {
+	Type                 string // "simulator" or "gateway"
+	SimulatorSeed        int64  // 0 seeds from the clock
+	SimulatorSuccessRate float64
+	SimulatorMinLatency  time.Duration
+	SimulatorMaxLatency  time.Duration
+	GatewayBaseURL       string
+	GatewayAPIKey        string
+	GatewayTimeout       time.Duration
+	GatewayMaxRetries    int
+	GatewayPollTimeout   time.Duration
+	WebhookSecret        string        // shared secret of the webhook signature, webhooks are rejected without it
+	WebhookTolerance     time.Duration // maximum age of a webhook timestamp
+}
+
+// WebhookConfig configures the delivery of merchant webhooks
+type WebhookConfig struct {
+	Concurrency    int
+	MaxAttempts    int
+	BaseBackoff    time.Duration
+	MaxBackoff     time.Duration
+	RequestTimeout time.Duration
+}
+
+// OutboxConfig configures the relay of the outbox events, merchant webhooks are always fed in addition to the sinks
+type OutboxConfig struct {
+	Sinks        []string // any of "log", "http" and "file"
+	HTTPURL      string
+	HTTPSecret   string
+	FilePath     string
+	PollInterval time.Duration
+}
+
+// FxConfig configures the exchange rates of cross-currency payments
+type FxConfig struct {
+	Provider    string   // "static" or "file"
+	StaticRates []string // rates of the static provider, e.g. "EUR/USD=1.08"
+	RatesFile   string   // JSON file of the file provider, reloaded when it changes
+	Spread      float64  // added on top of the rate, 0.005 is 0.5%
+	QuoteTTL    time.Duration
+}
+
 type AppConfig struct {
 	Name    string
 	Version string
@@ -59,10 +146,72 @@ func LoadConfig() *Config {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"), // optional
 		},
 		Redis: RedisConfig{
+			Enabled:  getEnv("REDIS_ENABLED", "false") == "true", // optional
 			Host:     getEnvOrPanic("REDIS_HOST"),
 			Port:     getEnvOrPanic("REDIS_PORT"),
 			Password: getEnvOrPanic("REDIS_PASSWORD"),
 		},
+		Lock: LockConfig{
+			TTL:            getEnvDuration("LOCK_TTL", 30*time.Second),            // optional
+			Wait:           getEnvDuration("LOCK_WAIT", 100*time.Millisecond),     // optional
+			ReaperInterval: getEnvDuration("LOCK_REAPER_INTERVAL", 1*time.Minute), // optional
+		},
+		Wallet: WalletConfig{
+			OverdraftLimit: getEnvFloat("WALLET_OVERDRAFT_LIMIT", 0), // optional
+		},
+		Idempotency: IdempotencyConfig{
+			StaleAfter: getEnvDuration("IDEMPOTENCY_STALE_AFTER", 2*time.Minute), // optional
+		},
+		Worker: WorkerConfig{
+			Concurrency:       getEnvInt("WORKER_CONCURRENCY", 4),                           // optional
+			PollInterval:      getEnvDuration("WORKER_POLL_INTERVAL", 500*time.Millisecond), // optional
+			MaxAttempts:       getEnvInt("WORKER_MAX_ATTEMPTS", 5),                          // optional
+			BaseBackoff:       getEnvDuration("WORKER_BASE_BACKOFF", 1*time.Second),         // optional
+			MaxBackoff:        getEnvDuration("WORKER_MAX_BACKOFF", 1*time.Minute),          // optional
+			VisibilityTimeout: getEnvDuration("WORKER_VISIBILITY_TIMEOUT", 1*time.Minute),   // optional
+		},
+		Recovery: RecoveryConfig{
+			Interval:         getEnvDuration("RECOVERY_INTERVAL", 5*time.Minute),           // optional
+			PendingThreshold: getEnvDuration("RECOVERY_PENDING_THRESHOLD", 10*time.Minute), // optional
+			FailAfter:        getEnvDuration("RECOVERY_FAIL_AFTER", 24*time.Hour),          // optional
+
+			ReconciliationInterval: getEnvDuration("RECONCILIATION_INTERVAL", 1*time.Hour), // optional
+		},
+		Processor: ProcessorConfig{
+			Type:                 getEnv("PROCESSOR_TYPE", "simulator"),                            // optional
+			SimulatorSeed:        int64(getEnvInt("PROCESSOR_SIMULATOR_SEED", 0)),                  // optional
+			SimulatorSuccessRate: getEnvFloat("PROCESSOR_SIMULATOR_SUCCESS_RATE", 0.9),             // optional
+			SimulatorMinLatency:  getEnvDuration("PROCESSOR_SIMULATOR_MIN_LATENCY", 1*time.Second), // optional
+			SimulatorMaxLatency:  getEnvDuration("PROCESSOR_SIMULATOR_MAX_LATENCY", 3*time.Second), // optional
+			GatewayBaseURL:       getEnv("PROCESSOR_GATEWAY_URL", "http://localhost:9090"),         // optional
+			GatewayAPIKey:        getEnv("PROCESSOR_GATEWAY_API_KEY", ""),                          // optional
+			GatewayTimeout:       getEnvDuration("PROCESSOR_GATEWAY_TIMEOUT", 5*time.Second),       // optional
+			GatewayMaxRetries:    getEnvInt("PROCESSOR_GATEWAY_MAX_RETRIES", 3),                    // optional
+			GatewayPollTimeout:   getEnvDuration("PROCESSOR_GATEWAY_POLL_TIMEOUT", 30*time.Second), // optional
+			WebhookSecret:        getEnv("PROCESSOR_WEBHOOK_SECRET", ""),                           // optional
+			WebhookTolerance:     getEnvDuration("PROCESSOR_WEBHOOK_TOLERANCE", 5*time.Minute),     // optional
+		},
+		Webhook: WebhookConfig{
+			Concurrency:    getEnvInt("WEBHOOK_CONCURRENCY", 2),                       // optional
+			MaxAttempts:    getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),                      // optional
+			BaseBackoff:    getEnvDuration("WEBHOOK_BASE_BACKOFF", 10*time.Second),    // optional
+			MaxBackoff:     getEnvDuration("WEBHOOK_MAX_BACKOFF", 1*time.Hour),        // optional
+			RequestTimeout: getEnvDuration("WEBHOOK_REQUEST_TIMEOUT", 10*time.Second), // optional
+		},
+		Outbox: OutboxConfig{
+			Sinks:        getEnvList("OUTBOX_SINKS", []string{"log"}),                  // optional
+			HTTPURL:      getEnv("OUTBOX_HTTP_URL", ""),                                // optional
+			HTTPSecret:   getEnv("OUTBOX_HTTP_SECRET", ""),                             // optional
+			FilePath:     getEnv("OUTBOX_FILE_PATH", "outbox-events.ndjson"),           // optional
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", 500*time.Millisecond), // optional
+		},
+		Fx: FxConfig{
+			Provider:    getEnv("FX_PROVIDER", "static"),                // optional
+			StaticRates: getEnvList("FX_STATIC_RATES", nil),             // optional
+			RatesFile:   getEnv("FX_RATES_FILE", "fx-rates.json"),       // optional
+			Spread:      getEnvFloat("FX_SPREAD", 0.005),                // optional
+			QuoteTTL:    getEnvDuration("FX_QUOTE_TTL", 30*time.Second), // optional
+		},
 		App: AppConfig{
 			Name:    getEnvOrPanic("APP_NAME"),
 			Version: getEnvOrPanic("APP_VERSION"),
@@ -77,6 +226,61 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvList returns the comma separated values of key, an empty value yields an empty list.
+func getEnvList(key string, defaultValue []string) []string {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		panic("Invalid integer for environment variable: " + key)
+	}
+	return number
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		panic("Invalid number for environment variable: " + key)
+	}
+	return number
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		panic("Invalid duration for environment variable: " + key)
+	}
+	return duration
+}
+
 func getEnvOrPanic(key string) string {
 	value := os.Getenv(key)
 	if value == "" {
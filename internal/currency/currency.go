@@ -0,0 +1,63 @@
+package currency
+
+import "github.com/shopspring/decimal"
+
+// Default is the currency of wallets and payments created before currencies existed,
+// and of requests that do not name a currency.
+const Default = "USD"
+
+// minorUnits maps the ISO-4217 codes the service accepts to the number of digits after the decimal point
+// their amounts may have.
+var minorUnits = map[string]int32{
+	"AUD": 2,
+	"BHD": 3,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"IDR": 2,
+	"INR": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MYR": 2,
+	"NZD": 2,
+	"PHP": 2,
+	"SGD": 2,
+	"THB": 2,
+	"TWD": 2,
+	"USD": 2,
+	"VND": 0,
+}
+
+// IsValid reports whether code is an ISO-4217 code the service accepts.
+func IsValid(code string) bool {
+	_, ok := minorUnits[code]
+	return ok
+}
+
+// MinorUnits is the number of digits after the decimal point amounts in the currency may have.
+func MinorUnits(code string) (int32, bool) {
+	units, ok := minorUnits[code]
+	return units, ok
+}
+
+// HasValidPrecision reports whether the amount has no more digits after the decimal point than the currency allows.
+// Trailing zeros do not count, "10.50" is a valid USD amount.
+func HasValidPrecision(amount decimal.Decimal, code string) bool {
+	units, ok := minorUnits[code]
+	if !ok {
+		return false
+	}
+	return amount.Equal(amount.Truncate(units))
+}
+
+// OrDefault returns code, or Default if code is empty.
+func OrDefault(code string) string {
+	if code == "" {
+		return Default
+	}
+	return code
+}
@@ -0,0 +1,40 @@
+package currency_test
+
+import (
+	"testing"
+
+	"payment-service/internal/currency"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasValidPrecision(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		expected bool
+	}{
+		{"10", "USD", true},
+		{"10.5", "USD", true},
+		{"10.50", "USD", true},
+		{"10.505", "USD", false},
+		{"1000", "JPY", true},
+		{"1000.00", "JPY", true},
+		{"1000.5", "JPY", false},
+		{"1.234", "KWD", true},
+		{"1.2345", "KWD", false},
+		{"10", "XXX", false},
+		{"10", "usd", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.amount+" "+tt.currency, func(t *testing.T) {
+			assert.Equal(t, tt.expected, currency.HasValidPrecision(decimal.RequireFromString(tt.amount), tt.currency))
+		})
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	assert.Equal(t, currency.Default, currency.OrDefault(""))
+	assert.Equal(t, "EUR", currency.OrDefault("EUR"))
+}
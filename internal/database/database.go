@@ -41,10 +41,33 @@ func InitDatabase(cfg *config.Config) error {
 		&models.User{},
 		&models.Wallet{},
 		&models.Payment{},
+		&models.IdempotencyRecord{},
+		&models.LockFence{},
+		&models.PaymentJob{},
+		&models.PaymentRecoveryAudit{},
+		&models.ProcessorEvent{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeliveryAttempt{},
+		&models.OutboxEvent{},
+		&models.LedgerEntry{},
+		&models.WalletReconciliation{},
+		&models.Refund{},
+		&models.PaymentStatusHistory{},
+		&models.Deposit{},
+		&models.Transfer{},
+		&models.FxQuote{},
 	); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	// a user had a single wallet before currencies existed, wallets are now unique per user and currency
+	if DB.Migrator().HasIndex(&models.Wallet{}, "idx_wallets_user_id") {
+		if err := DB.Migrator().DropIndex(&models.Wallet{}, "idx_wallets_user_id"); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
 	log.Println("Database migration completed")
 	return nil
 }
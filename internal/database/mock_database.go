@@ -119,6 +119,22 @@ func startTestDatabase() (*gorm.DB, testcontainers.Container, error) {
 		&models.User{},
 		&models.Wallet{},
 		&models.Payment{},
+		&models.IdempotencyRecord{},
+		&models.LockFence{},
+		&models.PaymentJob{},
+		&models.PaymentRecoveryAudit{},
+		&models.ProcessorEvent{},
+		&models.WebhookEndpoint{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeliveryAttempt{},
+		&models.OutboxEvent{},
+		&models.LedgerEntry{},
+		&models.WalletReconciliation{},
+		&models.Refund{},
+		&models.PaymentStatusHistory{},
+		&models.Deposit{},
+		&models.Transfer{},
+		&models.FxQuote{},
 	); err != nil {
 		_ = container.Terminate(ctx)
 		return nil, nil, fmt.Errorf("failed to migrate test DB: %w", err)
@@ -154,6 +170,54 @@ func CleanTestData() error {
 	}
 
 	return testDB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM fx_quotes").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM transfers").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM deposits").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM payment_status_history").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM refunds").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM wallet_reconciliations").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM ledger_entries").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM outbox_events").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM webhook_delivery_attempts").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM webhook_deliveries").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM webhook_endpoints").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM processor_events").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM payment_recovery_audits").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM payment_jobs").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM lock_fences").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM idempotency_records").Error; err != nil {
+			return err
+		}
 		if err := tx.Exec("DELETE FROM payments").Error; err != nil {
 			return err
 		}
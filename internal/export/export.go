@@ -0,0 +1,154 @@
+// Package export writes payments in the formats of the payment export, one payment at a time, so that an export
+// is streamed instead of held in memory.
+// The columns are a stable schema, independent of the JSON of the API: columns are only ever appended, and every
+// format has the same columns in the same order.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"payment-service/internal/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Columns of the export, in order. Amounts are decimal strings in their currency, timestamps are RFC 3339 in UTC.
+var Columns = []string{
+	"id",
+	"transaction_id",
+	"user_id",
+	"amount",
+	"currency",
+	"status",
+	"refunded_amount",
+	"wallet_currency",
+	"wallet_amount",
+	"fx_rate",
+	"fx_spread",
+	"fx_quote_id",
+	"failure_reason",
+	"created_at",
+	"updated_at",
+}
+
+// Writer writes the payments of an export, Flush must be called once all payments were written.
+type Writer interface {
+	Write(payment *models.Payment) error
+	Flush() error
+}
+
+// NewWriter returns the writer of the format, csv or ndjson.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+}
+
+// ContentType is the media type of the format.
+func ContentType(format string) string {
+	if format == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// values returns the columns of the payment, in the order of Columns.
+func values(payment *models.Payment) []string {
+	return []string{
+		fmt.Sprint(payment.ID),
+		payment.TransactionID,
+		payment.UserID,
+		payment.Amount.String(),
+		payment.Currency,
+		string(payment.Status),
+		payment.RefundedAmount.String(),
+		payment.ChargedCurrency(),
+		payment.ChargedAmount().String(),
+		payment.FxRate.String(),
+		payment.FxSpread.String(),
+		payment.FxQuoteID,
+		payment.FailureReason,
+		payment.CreatedAt.UTC().Format(time.RFC3339Nano),
+		payment.UpdatedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+// newCSVWriter writes a header line with the columns, then one line per payment.
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(payment *models.Payment) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.w.Write(values(payment))
+}
+
+// Flush writes the header of an export without payments too.
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	return w.w.Write(Columns)
+}
+
+type ndjsonWriter struct {
+	w *bufio.Writer
+}
+
+// newNDJSONWriter writes one JSON object per line and payment, with the columns as keys in their order and the
+// values as strings.
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	return &ndjsonWriter{w: bufio.NewWriter(w)}
+}
+
+func (w *ndjsonWriter) Write(payment *models.Payment) error {
+	w.w.WriteByte('{')
+	for i, value := range values(payment) {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		key, _ := json.Marshal(Columns[i])
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		w.w.Write(key)
+		w.w.WriteByte(':')
+		w.w.Write(encoded)
+	}
+	w.w.WriteByte('}')
+	return w.w.WriteByte('\n')
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,87 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-service/internal/export"
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payment() *models.Payment {
+	created := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	return &models.Payment{
+		ID:             7,
+		TransactionID:  "tx, \"quoted\"",
+		UserID:         "user-1",
+		Amount:         decimal.RequireFromString("100"),
+		Currency:       "EUR",
+		Status:         models.StatusPartiallyRefunded,
+		RefundedAmount: decimal.RequireFromString("30"),
+		WalletCurrency: "USD",
+		WalletAmount:   decimal.RequireFromString("108.54"),
+		FxRate:         decimal.RequireFromString("1.08"),
+		FxSpread:       decimal.RequireFromString("0.005"),
+		CreatedAt:      created,
+		UpdatedAt:      created.Add(time.Minute),
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatCSV, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(payment()))
+	require.NoError(t, w.Flush())
+
+	lines, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, export.Columns, lines[0])
+	assert.Equal(t, []string{"7", "tx, \"quoted\"", "user-1", "100", "EUR", "partially_refunded", "30", "USD", "108.54",
+		"1.08", "0.005", "", "", "2024-05-01T12:30:00Z", "2024-05-01T12:31:00Z"}, lines[1])
+}
+
+func TestCSVWithoutPaymentsHasHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatCSV, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+
+	assert.Equal(t, strings.Join(export.Columns, ",")+"\n", buf.String())
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := export.NewWriter(export.FormatNDJSON, &buf)
+	require.NoError(t, err)
+	require.NoError(t, w.Write(payment()))
+	require.NoError(t, w.Write(payment()))
+	require.NoError(t, w.Flush())
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+
+	var row map[string]string
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &row))
+	assert.Len(t, row, len(export.Columns))
+	assert.Equal(t, "tx, \"quoted\"", row["transaction_id"])
+	assert.Equal(t, "108.54", row["wallet_amount"])
+
+	// keys in the order of the columns
+	assert.True(t, strings.HasPrefix(lines[0], `{"id":"7","transaction_id":`))
+	assert.True(t, strings.HasSuffix(lines[0], `"updated_at":"2024-05-01T12:31:00Z"}`))
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := export.NewWriter("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
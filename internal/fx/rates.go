@@ -0,0 +1,172 @@
+// Package fx provides the exchange rates used to pay from a wallet in another currency than the payment's.
+// A rate is quoted as BASE/QUOTE, the number of units of the quote currency one unit of the base currency buys
+// at the mid-market; the spread the service charges on top is applied by the caller.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"payment-service/internal/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// ratePrecision is the number of decimals of a rate derived from its inverse
+const ratePrecision = 10
+
+type RateProvider interface {
+	// Rate returns the mid-market rate of base/quote, or ErrRateUnavailable
+	Rate(ctx context.Context, base, quote string) (decimal.Decimal, error)
+}
+
+// Options configures the conversions of cross-currency payments.
+type Options struct {
+	Spread   decimal.Decimal // charged on top of the mid-market rate, 0.005 is 0.5%
+	QuoteTTL time.Duration   // how long a quoted rate can be used for a payment
+}
+
+func DefaultOptions() Options {
+	return Options{Spread: decimal.RequireFromString("0.005"), QuoteTTL: 30 * time.Second}
+}
+
+// Convert is the amount in the quote currency for an amount in the base currency at the rate with the spread on top.
+// It is rounded up to the minor units of the quote currency, so the spread is never rounded away.
+func Convert(amount, rate, spread decimal.Decimal, quote string) decimal.Decimal {
+	units, _ := currency.MinorUnits(quote)
+	return amount.Mul(rate).Mul(decimal.NewFromInt(1).Add(spread)).RoundCeil(units)
+}
+
+// Pair is the key of a rate table, e.g. "EUR/USD".
+func Pair(base, quote string) string {
+	return base + "/" + quote
+}
+
+// lookup finds base/quote in the table, derives it from quote/base if only the inverse is listed,
+// a currency always converts to itself at 1.
+func lookup(rates map[string]decimal.Decimal, base, quote string) (decimal.Decimal, error) {
+	if base == quote {
+		return decimal.NewFromInt(1), nil
+	}
+	if rate, ok := rates[Pair(base, quote)]; ok {
+		return rate, nil
+	}
+	if inverse, ok := rates[Pair(quote, base)]; ok {
+		return decimal.NewFromInt(1).DivRound(inverse, ratePrecision), nil
+	}
+	return decimal.Zero, fmt.Errorf("%w: %s", ErrRateUnavailable, Pair(base, quote))
+}
+
+// ParseRates parses rates written as "EUR/USD=1.08". Both currencies must be accepted ISO-4217 codes,
+// and rates must be positive.
+func ParseRates(entries []string) (map[string]decimal.Decimal, error) {
+	rates := make(map[string]decimal.Decimal, len(entries))
+	for _, entry := range entries {
+		pair, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q, expected BASE/QUOTE=RATE", entry)
+		}
+		if err := addRate(rates, strings.TrimSpace(pair), strings.TrimSpace(value)); err != nil {
+			return nil, err
+		}
+	}
+	return rates, nil
+}
+
+func addRate(rates map[string]decimal.Decimal, pair, value string) error {
+	base, quote, ok := strings.Cut(pair, "/")
+	if !ok || !currency.IsValid(base) || !currency.IsValid(quote) {
+		return fmt.Errorf("invalid currency pair %q", pair)
+	}
+	rate, err := decimal.NewFromString(value)
+	if err != nil || !rate.IsPositive() {
+		return fmt.Errorf("invalid rate %q for %s", value, pair)
+	}
+	rates[Pair(base, quote)] = rate
+	return nil
+}
+
+// StaticProvider serves rates from a fixed table.
+type StaticProvider struct {
+	rates map[string]decimal.Decimal
+}
+
+func NewStaticProvider(rates map[string]decimal.Decimal) *StaticProvider {
+	return &StaticProvider{rates: rates}
+}
+
+func (p *StaticProvider) Rate(ctx context.Context, base, quote string) (decimal.Decimal, error) {
+	return lookup(p.rates, base, quote)
+}
+
+// FileProvider serves rates from a JSON file mapping pairs to rates, e.g. {"EUR/USD": "1.08"}.
+// The file is read again when it was modified, so rates can be updated without a restart;
+// if it becomes unreadable or invalid the last good rates keep being served,
+// and an invalid version is not parsed again until the file is modified.
+type FileProvider struct {
+	path string
+
+	mu       sync.Mutex
+	modified time.Time
+	rates    map[string]decimal.Decimal
+}
+
+// NewFileProvider reads the rates file, it fails if the file is missing or invalid.
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Rate(ctx context.Context, base, quote string) (decimal.Decimal, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if info, err := os.Stat(p.path); err == nil && info.ModTime().After(p.modified) {
+		_ = p.reloadLocked()
+	}
+	return lookup(p.rates, base, quote)
+}
+
+func (p *FileProvider) reload() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.reloadLocked()
+}
+
+func (p *FileProvider) reloadLocked() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	// an invalid file is parsed once, not on every rate, until it is modified again
+	p.modified = info.ModTime()
+
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("invalid rates file %s: %w", p.path, err)
+	}
+	rates := make(map[string]decimal.Decimal, len(entries))
+	for pair, value := range entries {
+		if err := addRate(rates, pair, value); err != nil {
+			return fmt.Errorf("invalid rates file %s: %w", p.path, err)
+		}
+	}
+
+	p.rates = rates
+	return nil
+}
@@ -0,0 +1,115 @@
+package fx_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"payment-service/internal/fx"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func d(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestStaticProvider(t *testing.T) {
+	rates, err := fx.ParseRates([]string{"EUR/USD=1.25", "USD/JPY = 150"})
+	require.NoError(t, err)
+	provider := fx.NewStaticProvider(rates)
+
+	tests := []struct {
+		base, quote string
+		expected    string
+		err         error
+	}{
+		{"EUR", "USD", "1.25", nil},
+		{"USD", "EUR", "0.8", nil},
+		{"USD", "JPY", "150", nil},
+		{"USD", "USD", "1", nil},
+		{"EUR", "JPY", "0", fx.ErrRateUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(fx.Pair(tt.base, tt.quote), func(t *testing.T) {
+			rate, err := provider.Rate(context.Background(), tt.base, tt.quote)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, rate.Equal(d(tt.expected)), "rate %s, expected %s", rate, tt.expected)
+		})
+	}
+}
+
+func TestParseRatesRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"EUR/USD", "EURUSD=1.1", "EUR/XXX=1.1", "EUR/USD=abc", "EUR/USD=0", "EUR/USD=-1"} {
+		_, err := fx.ParseRates([]string{entry})
+		assert.Error(t, err, entry)
+	}
+}
+
+func TestFileProviderReloadsModifiedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"EUR/USD": "1.10"}`), 0o644))
+
+	provider, err := fx.NewFileProvider(path)
+	require.NoError(t, err)
+	rate, err := provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, rate.Equal(d("1.10")))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"EUR/USD": "1.20"}`), 0o644))
+	later := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	rate, err = provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, rate.Equal(d("1.20")), "rate %s should be reloaded", rate)
+
+	// an invalid update keeps the last good rates
+	require.NoError(t, os.WriteFile(path, []byte(`not json`), 0o644))
+	later = later.Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	rate, err = provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, rate.Equal(d("1.20")))
+
+	// the invalid version was read once, only a later modification is read again
+	require.NoError(t, os.WriteFile(path, []byte(`{"EUR/USD": "1.30"}`), 0o644))
+	require.NoError(t, os.Chtimes(path, later, later))
+	rate, err = provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, rate.Equal(d("1.20")), "rate %s should not be reloaded", rate)
+
+	later = later.Add(time.Second)
+	require.NoError(t, os.Chtimes(path, later, later))
+	rate, err = provider.Rate(context.Background(), "EUR", "USD")
+	require.NoError(t, err)
+	assert.True(t, rate.Equal(d("1.30")), "rate %s should be reloaded", rate)
+
+	_, err = fx.NewFileProvider(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		amount, rate, spread string
+		quote                string
+		expected             string
+	}{
+		{"100", "1.25", "0", "USD", "125"},
+		{"100", "1.25", "0.01", "USD", "126.25"},
+		{"10", "0.333", "0", "USD", "3.33"},
+		{"10", "0.3333", "0", "USD", "3.34"},
+		{"10", "150.5", "0.005", "JPY", "1513"},
+	}
+	for _, tt := range tests {
+		converted := fx.Convert(d(tt.amount), d(tt.rate), d(tt.spread), tt.quote)
+		assert.True(t, converted.Equal(d(tt.expected)), "%s at %s+%s: %s, expected %s", tt.amount, tt.rate, tt.spread, converted, tt.expected)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DepositHandler struct {
+	depositService services.DepositService
+}
+
+func NewDepositHandler(depositService services.DepositService) *DepositHandler {
+	return &DepositHandler{
+		depositService: depositService,
+	}
+}
+
+func (h *DepositHandler) Deposit(c *gin.Context) {
+	var req models.DepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	deposit, created, err := h.depositService.Deposit(c, c.Param("userId"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDepositIDConflict):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyConflict, "Deposit id reused", err)
+		case errors.Is(err, services.ErrCurrencyMismatch):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeCurrencyMismatch, "No wallet in the deposit currency", err)
+		case errors.Is(err, services.ErrDepositInProgress):
+			response.ErrorCodeResponse(c, http.StatusConflict, response.CodeIdempotencyKeyInProgress, "Deposit in progress", err)
+		default:
+			notFoundOrInternal(c, err, "Failed to deposit")
+		}
+		return
+	}
+
+	if !created {
+		response.SuccessResponse(c, http.StatusOK, "Deposit already processed", deposit)
+		return
+	}
+	response.SuccessResponse(c, http.StatusCreated, "Deposit processed successfully", deposit)
+}
+
+func (h *DepositHandler) GetDeposits(c *gin.Context) {
+	deposits, err := h.depositService.GetDeposits(c.Param("userId"))
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get deposits")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", deposits)
+}
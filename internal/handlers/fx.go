@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"payment-service/internal/fx"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FxHandler struct {
+	fxService services.FxService
+}
+
+func NewFxHandler(fxService services.FxService) *FxHandler {
+	return &FxHandler{
+		fxService: fxService,
+	}
+}
+
+func (h *FxHandler) CreateQuote(c *gin.Context) {
+	var req models.FxQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	quote, err := h.fxService.CreateQuote(c, &req)
+	if err != nil {
+		if fxErrorResponse(c, err) {
+			return
+		}
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to create quote", err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Quote created successfully", quote)
+}
+
+// fxErrorResponse writes the response for an FX error, it returns false if err is not one.
+func fxErrorResponse(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrQuoteInvalid):
+		response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeQuoteInvalid, "Quote does not match the payment", err)
+	case errors.Is(err, services.ErrQuoteExpired):
+		response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeQuoteExpired, "Quote expired", err)
+	case errors.Is(err, fx.ErrRateUnavailable):
+		response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeFxRateUnavailable, "No exchange rate for the currencies", err)
+	default:
+		return false
+	}
+	return true
+}
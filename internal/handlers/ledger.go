@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerHandler struct {
+	ledgerService services.LedgerService
+}
+
+func NewLedgerHandler(ledgerService services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{
+		ledgerService: ledgerService,
+	}
+}
+
+func (h *LedgerHandler) GetWalletLedger(c *gin.Context) {
+	ledger, err := h.ledgerService.GetWalletLedger(c.Param("userId"), c.DefaultQuery("currency", currency.Default))
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get wallet ledger")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", ledger)
+}
+
+func (h *LedgerHandler) Reconcile(c *gin.Context) {
+	mismatches, err := h.ledgerService.Reconcile()
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to reconcile wallets", err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", mismatches)
+}
+
+func (h *LedgerHandler) GetReconciliations(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		response.ErrorResponse(c, http.StatusBadRequest, "Invalid limit", err)
+		return
+	}
+
+	records, err := h.ledgerService.GetReconciliations(limit)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to get reconciliations", err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", records)
+}
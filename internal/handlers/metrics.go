@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"payment-service/internal/redis"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsHandler struct {
+	locker redis.Locker
+}
+
+func NewMetricsHandler(locker redis.Locker) *MetricsHandler {
+	return &MetricsHandler{
+		locker: locker,
+	}
+}
+
+func (h *MetricsHandler) GetLockMetrics(c *gin.Context) {
+	response.SuccessResponse(c, http.StatusOK, "success", h.locker.Metrics())
+}
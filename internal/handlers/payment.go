@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"compress/gzip"
 	"errors"
+	"io"
 	"net/http"
 
+	"payment-service/internal/export"
 	"payment-service/internal/models"
 	"payment-service/internal/services"
 	"payment-service/internal/utils/response"
@@ -44,6 +47,17 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 
 	payment, err := h.paymentService.ProcessPayment(c, &req)
 	if err != nil {
+		if errors.Is(err, services.ErrIdempotencyKeyConflict) {
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyConflict, "Transaction id reused", err)
+			return
+		}
+		if errors.Is(err, services.ErrCurrencyMismatch) {
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeCurrencyMismatch, "No wallet in the payment currency", err)
+			return
+		}
+		if fxErrorResponse(c, err) {
+			return
+		}
 		response.ErrorResponse(c, http.StatusBadRequest, "Failed to process payment", err)
 		return
 	}
@@ -66,12 +80,91 @@ func (h *PaymentHandler) GetPaymentByTransactionID(c *gin.Context) {
 	response.SuccessResponse(c, http.StatusOK, "success", payment)
 }
 
-func (h *PaymentHandler) GetAll(c *gin.Context) {
-	payment, err := h.paymentService.GetAll()
+func (h *PaymentHandler) GetPaymentHistory(c *gin.Context) {
+	history, err := h.paymentService.GetPaymentHistory(c.Param("transactionId"))
 	if err != nil {
-		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to get all payment", err)
+		notFoundOrInternal(c, err, "Failed to get payment history")
 		return
 	}
 
-	response.SuccessResponse(c, http.StatusOK, "success", payment)
+	response.SuccessResponse(c, http.StatusOK, "success", history)
+}
+
+func (h *PaymentHandler) ListPayments(c *gin.Context) {
+	var query models.PaymentListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	payments, page, err := h.paymentService.ListPayments(&query.PaymentFilter, &query.PageQuery)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			response.ValidationErrorResponse(c, err)
+			return
+		}
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to list payments", err)
+		return
+	}
+
+	response.PageResponse(c, http.StatusOK, "success", payments, page)
+}
+
+// ExportPayments streams the payments matching the filter as a CSV or NDJSON file, optionally gzipped, see package export.
+// A failure before anything was sent is answered as usual, later the export is cut short and the error is attached
+// to the request, so that it shows in the access log.
+func (h *PaymentHandler) ExportPayments(c *gin.Context) {
+	var query models.PaymentExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	filename, contentType := "payments."+query.Format, export.ContentType(query.Format)
+	var out io.Writer = c.Writer
+	var gz *gzip.Writer
+	if query.Gzip {
+		gz = gzip.NewWriter(c.Writer)
+		out = gz
+		filename, contentType = filename+".gz", "application/gzip"
+	}
+	writer, err := export.NewWriter(query.Format, out)
+	if err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	err = h.paymentService.ExportPayments(c.Request.Context(), &query.PaymentFilter, writer.Write)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			response.ErrorResponse(c, http.StatusInternalServerError, "Failed to export payments", err)
+			return
+		}
+		_ = c.Error(err)
+		c.Abort()
+	}
+}
+
+func (h *PaymentHandler) CancelPayment(c *gin.Context) {
+	payment, err := h.paymentService.CancelPayment(c.Param("transactionId"))
+	if err != nil {
+		if errors.Is(err, services.ErrPaymentNotCancellable) {
+			response.ErrorCodeResponse(c, http.StatusConflict, response.CodePaymentNotCancellable, "Payment cannot be cancelled", err)
+			return
+		}
+		notFoundOrInternal(c, err, "Failed to cancel payment")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Payment cancelled successfully", payment)
 }
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundHandler struct {
+	refundService services.RefundService
+}
+
+func NewRefundHandler(refundService services.RefundService) *RefundHandler {
+	return &RefundHandler{
+		refundService: refundService,
+	}
+}
+
+func (h *RefundHandler) CreateRefund(c *gin.Context) {
+	var req models.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	refund, created, err := h.refundService.CreateRefund(c.Param("transactionId"), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefundIDConflict):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyConflict, "Refund id reused", err)
+		case errors.Is(err, services.ErrPaymentNotRefundable):
+			response.ErrorCodeResponse(c, http.StatusConflict, response.CodePaymentNotRefundable, "Payment cannot be refunded", err)
+		case errors.Is(err, services.ErrRefundExceedsPayment):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeRefundExceedsPayment, "Refund exceeds payment", err)
+		case errors.Is(err, services.ErrRefundPrecision):
+			response.ErrorResponse(c, http.StatusBadRequest, "Invalid refund amount", err)
+		case errors.Is(err, models.ErrInvalidAmount):
+			// a refund too small to give back a minor unit of the converted wallet amount
+			response.ErrorResponse(c, http.StatusBadRequest, "Refund amount too small", err)
+		default:
+			notFoundOrInternal(c, err, "Failed to refund payment")
+		}
+		return
+	}
+
+	if !created {
+		response.SuccessResponse(c, http.StatusOK, "Refund already processed", refund)
+		return
+	}
+	response.SuccessResponse(c, http.StatusCreated, "Refund processed successfully", refund)
+}
+
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	refunds, err := h.refundService.GetRefunds(c.Param("transactionId"))
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get refunds")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", refunds)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	transferService services.TransferService
+}
+
+func NewTransferHandler(transferService services.TransferService) *TransferHandler {
+	return &TransferHandler{
+		transferService: transferService,
+	}
+}
+
+func (h *TransferHandler) Transfer(c *gin.Context) {
+	var req models.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	transfer, created, err := h.transferService.Transfer(c, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTransferIDConflict):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyConflict, "Transfer id reused", err)
+		case errors.Is(err, services.ErrCurrencyMismatch):
+			response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeCurrencyMismatch, "No wallet in the transfer currency", err)
+		case errors.Is(err, services.ErrTransferInProgress):
+			response.ErrorCodeResponse(c, http.StatusConflict, response.CodeIdempotencyKeyInProgress, "Transfer in progress", err)
+		case errors.Is(err, services.ErrSelfTransfer), errors.Is(err, models.ErrInsufficientFunds):
+			response.ErrorResponse(c, http.StatusBadRequest, "Failed to transfer", err)
+		default:
+			notFoundOrInternal(c, err, "Failed to transfer")
+		}
+		return
+	}
+
+	if !created {
+		response.SuccessResponse(c, http.StatusOK, "Transfer already processed", transfer)
+		return
+	}
+	response.SuccessResponse(c, http.StatusCreated, "Transfer processed successfully", transfer)
+}
+
+func (h *TransferHandler) GetTransfer(c *gin.Context) {
+	transfer, err := h.transferService.GetTransfer(c.Param("transferId"))
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get transfer")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", transfer)
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"payment-service/internal/models"
 	"payment-service/internal/services"
 	"payment-service/internal/utils/response"
 
@@ -42,9 +44,59 @@ func (h *UserHandler) GetDetail(c *gin.Context) {
 	userId := c.Param("userId")
 	user, err := h.userService.GetUserDetail(userId)
 	if err != nil {
-		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to get all user", err)
+		notFoundOrInternal(c, err, "Failed to get user")
 		return
 	}
 
 	response.SuccessResponse(c, http.StatusOK, "success", user)
 }
+
+func (h *UserHandler) CreateWallet(c *gin.Context) {
+	var req models.WalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	wallet, err := h.userService.CreateWallet(c.Param("userId"), req.Currency)
+	if err != nil {
+		if errors.Is(err, services.ErrWalletExists) {
+			response.ErrorResponse(c, http.StatusConflict, "Wallet already exists", err)
+			return
+		}
+		notFoundOrInternal(c, err, "Failed to create wallet")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Wallet created successfully", wallet)
+}
+
+func (h *UserHandler) GetWallets(c *gin.Context) {
+	wallets, err := h.userService.GetWallets(c.Param("userId"))
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get wallets")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", wallets)
+}
+
+func (h *UserHandler) GetPayments(c *gin.Context) {
+	var query models.UserPaymentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	payments, page, err := h.userService.GetPayments(c.Param("userId"), &query)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			response.ValidationErrorResponse(c, err)
+			return
+		}
+		notFoundOrInternal(c, err, "Failed to get payments")
+		return
+	}
+
+	response.PageResponse(c, http.StatusOK, "success", payments, page)
+}
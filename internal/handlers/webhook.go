@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/processor"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookHandler struct {
+	paymentService services.PaymentService
+	secret         string
+	tolerance      time.Duration
+}
+
+func NewWebhookHandler(paymentService services.PaymentService, secret string, tolerance time.Duration) *WebhookHandler {
+	return &WebhookHandler{
+		paymentService: paymentService,
+		secret:         secret,
+		tolerance:      tolerance,
+	}
+}
+
+// ProcessorWebhook receives payment results reported by the processor.
+// The signature is verified over the raw body before anything is parsed.
+func (h *WebhookHandler) ProcessorWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusBadRequest, "Failed to read request body", err)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	signature := c.GetHeader(processor.WebhookSignatureHeader)
+	timestamp := c.GetHeader(processor.WebhookTimestampHeader)
+	if err := processor.VerifyWebhook(h.secret, signature, timestamp, body, time.Now(), h.tolerance); err != nil {
+		response.ErrorResponse(c, http.StatusUnauthorized, "Invalid webhook signature", err)
+		return
+	}
+
+	var event models.ProcessorEventRequest
+	if err := c.ShouldBindJSON(&event); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	payment, applied, err := h.paymentService.ApplyProcessorEvent(&event)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.ErrorResponse(c, http.StatusNotFound, "Failed to get payment by reference", err)
+		} else {
+			response.ErrorResponse(c, http.StatusInternalServerError, "Failed to apply processor event", err)
+		}
+		return
+	}
+
+	if !applied {
+		response.SuccessResponse(c, http.StatusOK, "Event already processed", payment)
+		return
+	}
+	response.SuccessResponse(c, http.StatusOK, "Event processed", payment)
+}
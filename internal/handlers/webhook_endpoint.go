@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type WebhookEndpointHandler struct {
+	webhookService services.WebhookService
+}
+
+func NewWebhookEndpointHandler(webhookService services.WebhookService) *WebhookEndpointHandler {
+	return &WebhookEndpointHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookEndpointHandler) Register(c *gin.Context) {
+	var req models.WebhookEndpointRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationErrorResponse(c, err)
+		return
+	}
+
+	endpoint, err := h.webhookService.RegisterEndpoint(&req)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to register webhook endpoint", err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusCreated, "Webhook endpoint registered successfully", endpoint)
+}
+
+func (h *WebhookEndpointHandler) GetAll(c *gin.Context) {
+	endpoints, err := h.webhookService.GetEndpoints()
+	if err != nil {
+		response.ErrorResponse(c, http.StatusInternalServerError, "Failed to get all webhook endpoints", err)
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", endpoints)
+}
+
+func (h *WebhookEndpointHandler) GetDetail(c *gin.Context) {
+	id, ok := idParam(c, "endpointId")
+	if !ok {
+		return
+	}
+
+	endpoint, err := h.webhookService.GetEndpoint(id)
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get webhook endpoint")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", endpoint)
+}
+
+func (h *WebhookEndpointHandler) Deactivate(c *gin.Context) {
+	id, ok := idParam(c, "endpointId")
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeactivateEndpoint(id); err != nil {
+		notFoundOrInternal(c, err, "Failed to deactivate webhook endpoint")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "Webhook endpoint deactivated", nil)
+}
+
+func (h *WebhookEndpointHandler) GetDeliveries(c *gin.Context) {
+	id, ok := idParam(c, "endpointId")
+	if !ok {
+		return
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(id)
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get webhook deliveries")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", deliveries)
+}
+
+func (h *WebhookEndpointHandler) RedeliverFailed(c *gin.Context) {
+	id, ok := idParam(c, "endpointId")
+	if !ok {
+		return
+	}
+
+	count, err := h.webhookService.RedeliverFailed(id)
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to redeliver failed webhook deliveries")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusAccepted, "Failed deliveries queued", gin.H{"queued": count})
+}
+
+func (h *WebhookEndpointHandler) GetDelivery(c *gin.Context) {
+	id, ok := idParam(c, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.GetDelivery(id)
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to get webhook delivery")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusOK, "success", delivery)
+}
+
+func (h *WebhookEndpointHandler) Redeliver(c *gin.Context) {
+	id, ok := idParam(c, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(id)
+	if err != nil {
+		notFoundOrInternal(c, err, "Failed to redeliver webhook delivery")
+		return
+	}
+
+	response.SuccessResponse(c, http.StatusAccepted, "Delivery queued", delivery)
+}
+
+// idParam parses a numeric path parameter, it answers 400 and returns false if it is invalid.
+func idParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		response.ErrorResponse(c, http.StatusBadRequest, "Invalid "+name, err)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func notFoundOrInternal(c *gin.Context, err error, message string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.ErrorResponse(c, http.StatusNotFound, message, err)
+	} else {
+		response.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
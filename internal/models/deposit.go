@@ -0,0 +1,54 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"payment-service/internal/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+type DepositStatus string
+
+const (
+	DepositPending   DepositStatus = "pending"   // recorded, the wallet is not credited yet
+	DepositCompleted DepositStatus = "completed" // the wallet was credited
+)
+
+// Deposit tops up a user's wallet with funds from outside the service.
+// The deposit ID is chosen by the client and makes the request idempotent.
+type Deposit struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	DepositID   string          `json:"deposit_id" gorm:"uniqueIndex;size:255;not null"`
+	UserID      string          `json:"user_id" gorm:"not null;index"`
+	Amount      decimal.Decimal `json:"amount" gorm:"not null"`
+	Currency    string          `json:"currency" gorm:"size:3;not null;default:'USD'"`
+	Status      DepositStatus   `json:"status" gorm:"not null;default:pending"`
+	RequestHash string          `json:"-" gorm:"not null"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type DepositRequest struct {
+	DepositID string          `json:"deposit_id" binding:"required"`
+	Amount    decimal.Decimal `json:"amount" binding:"required,decimalGt=0,decimalPrecision=Currency"`
+	Currency  string          `json:"currency" binding:"omitempty,currency"` // ISO-4217 code, the default currency if empty
+}
+
+// Hash returns a canonical hash of the deposit request for the user, used to detect a deposit_id that is reused
+// with a different payload.
+func (req *DepositRequest) Hash(userID string) string {
+	hash := sha256.New()
+	hash.Write([]byte(userID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.DepositID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Amount.String()))
+	if code := currency.OrDefault(req.Currency); code != currency.Default {
+		hash.Write([]byte{0})
+		hash.Write([]byte(code))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// FxQuote is an exchange rate offered for paying in Currency from a wallet in WalletCurrency.
+// A payment that names the quote before ExpiresAt is converted at the quoted rate and spread.
+type FxQuote struct {
+	ID             uint            `json:"-" gorm:"primaryKey"`
+	QuoteID        string          `json:"quote_id" gorm:"uniqueIndex;size:64;not null"`
+	Currency       string          `json:"currency" gorm:"size:3;not null"`
+	WalletCurrency string          `json:"wallet_currency" gorm:"size:3;not null"`
+	Rate           decimal.Decimal `json:"rate" gorm:"not null"` // mid-market, wallet currency per unit of currency
+	Spread         decimal.Decimal `json:"spread" gorm:"not null"`
+	CustomerRate   decimal.Decimal `json:"customer_rate" gorm:"not null"` // the rate with the spread on top
+	ExpiresAt      time.Time       `json:"expires_at" gorm:"not null"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type FxQuoteRequest struct {
+	Currency       string `json:"currency" binding:"required,currency"`
+	WalletCurrency string `json:"wallet_currency" binding:"required,currency,nefield=Currency"`
+}
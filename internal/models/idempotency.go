@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyRecord stores the outcome of a request sent with an Idempotency-Key header,
+// so that retries of the same request can be answered with a byte-identical replay.
+// A record with a zero StatusCode is still being processed.
+type IdempotencyRecord struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	IdempotencyKey string    `json:"idempotency_key" gorm:"size:255;not null;uniqueIndex"`
+	Method         string    `json:"method" gorm:"not null"`
+	Path           string    `json:"path" gorm:"not null"`
+	RequestHash    string    `json:"request_hash" gorm:"not null"`
+	StatusCode     int       `json:"status_code" gorm:"not null;default:0"`
+	ContentType    string    `json:"content_type"`
+	ResponseBody   []byte    `json:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (r *IdempotencyRecord) IsCompleted() bool {
+	return r.StatusCode != 0
+}
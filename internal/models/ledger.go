@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-service/internal/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+type LedgerDirection string
+
+const (
+	Debit  LedgerDirection = "debit"
+	Credit LedgerDirection = "credit"
+)
+
+// Ledger accounts outside the wallets
+const (
+	// AccountFunding is the counter account of money that enters wallets, e.g. the opening balance
+	AccountFunding = "external:funding"
+	// AccountProcessorSettlement is the counter account of money that leaves wallets through the payment processor
+	AccountProcessorSettlement = "processor:settlement"
+)
+
+var ErrUnbalancedPosting = errors.New("ledger posting is not balanced")
+
+// WalletAccount is the ledger account of a user's wallet in the currency.
+// Credits increase the balance of a wallet and debits decrease it.
+// Wallets in the default currency keep the account they had before currencies existed.
+func WalletAccount(userID, code string) string {
+	if code == currency.Default {
+		return "wallet:" + userID
+	}
+	return "wallet:" + userID + ":" + code
+}
+
+// LedgerEntry is one side of a double-entry posting. The entries of a posting share the reference,
+// and their debits and credits sum up to the same amount. Entries are never updated or deleted.
+type LedgerEntry struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	Reference   string          `json:"reference" gorm:"not null;index"`
+	Account     string          `json:"account" gorm:"not null;index"`
+	WalletID    *uint           `json:"wallet_id,omitempty" gorm:"index"`
+	PaymentID   *uint           `json:"payment_id,omitempty" gorm:"index"`
+	Direction   LedgerDirection `json:"direction" gorm:"not null"`
+	Amount      decimal.Decimal `json:"amount" gorm:"not null"`
+	Currency    string          `json:"currency" gorm:"size:3;not null;default:'USD'"`
+	Description string          `json:"description"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// SignedAmount is the amount as it changes the balance of a wallet account.
+func (e *LedgerEntry) SignedAmount() decimal.Decimal {
+	if e.Direction == Debit {
+		return e.Amount.Neg()
+	}
+	return e.Amount
+}
+
+// CheckBalanced verifies that the entries form a valid posting: one reference, one currency, positive amounts,
+// and debits that equal credits.
+func CheckBalanced(entries []*LedgerEntry) error {
+	if len(entries) < 2 {
+		return fmt.Errorf("%w: a posting needs at least two entries", ErrUnbalancedPosting)
+	}
+
+	debits, credits := decimal.Zero, decimal.Zero
+	for _, entry := range entries {
+		if entry.Reference != entries[0].Reference {
+			return fmt.Errorf("%w: entries have different references", ErrUnbalancedPosting)
+		}
+		if entry.Currency != entries[0].Currency {
+			return fmt.Errorf("%w: entries have different currencies", ErrUnbalancedPosting)
+		}
+		if !entry.Amount.IsPositive() {
+			return fmt.Errorf("%w: amount %s is not positive", ErrUnbalancedPosting, entry.Amount)
+		}
+		switch entry.Direction {
+		case Debit:
+			debits = debits.Add(entry.Amount)
+		case Credit:
+			credits = credits.Add(entry.Amount)
+		default:
+			return fmt.Errorf("%w: unknown direction %q", ErrUnbalancedPosting, entry.Direction)
+		}
+	}
+
+	if !debits.Equal(credits) {
+		return fmt.Errorf("%w: debits %s, credits %s", ErrUnbalancedPosting, debits, credits)
+	}
+	return nil
+}
+
+// WalletReconciliation records a wallet whose stored balance differed from the sum of its ledger entries.
+type WalletReconciliation struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	WalletID      uint            `json:"wallet_id" gorm:"not null;index"`
+	UserID        string          `json:"user_id" gorm:"not null"`
+	StoredBalance decimal.Decimal `json:"stored_balance" gorm:"not null"`
+	LedgerBalance decimal.Decimal `json:"ledger_balance" gorm:"not null"`
+	Difference    decimal.Decimal `json:"difference" gorm:"not null"`
+	CheckedAt     time.Time       `json:"checked_at" gorm:"not null;index"`
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"testing"
+
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func entry(reference string, direction models.LedgerDirection, amount int64) *models.LedgerEntry {
+	return &models.LedgerEntry{Reference: reference, Account: "account", Direction: direction, Amount: decimal.NewFromInt(amount)}
+}
+
+func inCurrency(e *models.LedgerEntry, code string) *models.LedgerEntry {
+	e.Currency = code
+	return e
+}
+
+func TestCheckBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []*models.LedgerEntry
+		balanced bool
+	}{
+		{"debit equals credit", []*models.LedgerEntry{entry("p1", models.Debit, 100), entry("p1", models.Credit, 100)}, true},
+		{"split credit", []*models.LedgerEntry{entry("p1", models.Debit, 100), entry("p1", models.Credit, 60), entry("p1", models.Credit, 40)}, true},
+		{"debit differs from credit", []*models.LedgerEntry{entry("p1", models.Debit, 100), entry("p1", models.Credit, 90)}, false},
+		{"single entry", []*models.LedgerEntry{entry("p1", models.Debit, 100)}, false},
+		{"no entries", nil, false},
+		{"zero amounts", []*models.LedgerEntry{entry("p1", models.Debit, 0), entry("p1", models.Credit, 0)}, false},
+		{"negative amounts", []*models.LedgerEntry{entry("p1", models.Debit, -100), entry("p1", models.Credit, -100)}, false},
+		{"different references", []*models.LedgerEntry{entry("p1", models.Debit, 100), entry("p2", models.Credit, 100)}, false},
+		{"different currencies", []*models.LedgerEntry{entry("p1", models.Debit, 100), inCurrency(entry("p1", models.Credit, 100), "EUR")}, false},
+		{"unknown direction", []*models.LedgerEntry{entry("p1", models.Debit, 100), entry("p1", "sideways", 100)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := models.CheckBalanced(tt.entries)
+			if tt.balanced {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, models.ErrUnbalancedPosting)
+			}
+		})
+	}
+}
+
+func TestSignedAmount(t *testing.T) {
+	assert.True(t, entry("p1", models.Credit, 100).SignedAmount().Equal(decimal.NewFromInt(100)))
+	assert.True(t, entry("p1", models.Debit, 100).SignedAmount().Equal(decimal.NewFromInt(-100)))
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// LockFence keeps the highest fencing token that wrote under a lock key.
+// A write carrying a lower token comes from a holder whose lease already expired and is rejected.
+type LockFence struct {
+	LockKey    string    `json:"lock_key" gorm:"primaryKey"`
+	FenceToken uint64    `json:"fence_token" gorm:"not null"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
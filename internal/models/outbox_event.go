@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+type PaymentEventType string
+
+const (
+	EventPaymentCompleted PaymentEventType = "payment.completed"
+	EventPaymentFailed    PaymentEventType = "payment.failed"
+	EventPaymentCancelled PaymentEventType = "payment.cancelled"
+	EventPaymentRefunded  PaymentEventType = "payment.refunded" // sent for every refund, partial or full
+)
+
+// PaymentEvent is the JSON payload of a payment domain event, it is what sinks and merchant webhooks receive.
+// The ID is the same for every publication and redelivery of an event, consumers use it to drop duplicates.
+type PaymentEvent struct {
+	ID        string           `json:"id"`
+	Type      PaymentEventType `json:"type"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      *Payment         `json:"data"`
+}
+
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"
+	OutboxPublished OutboxStatus = "published"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the state change it describes,
+// the outbox relay publishes it afterwards. An event is therefore published if and only if the change
+// was committed, at least once.
+type OutboxEvent struct {
+	ID            uint             `json:"id" gorm:"primaryKey"`
+	EventID       string           `json:"event_id" gorm:"uniqueIndex;size:255;not null"`
+	AggregateType string           `json:"aggregate_type" gorm:"not null"`
+	AggregateID   uint             `json:"aggregate_id" gorm:"not null;index"`
+	EventType     PaymentEventType `json:"event_type" gorm:"not null"`
+	Payload       []byte           `json:"-" gorm:"not null"`
+	Status        OutboxStatus     `json:"status" gorm:"not null;default:pending;index:idx_outbox_events_claim,priority:1"`
+	Attempts      int              `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt time.Time        `json:"next_attempt_at" gorm:"not null;index:idx_outbox_events_claim,priority:2"`
+	LastError     string           `json:"last_error"`
+	PublishedAt   *time.Time       `json:"published_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
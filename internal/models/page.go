@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+
+	SortCreatedAtAsc  = "created_at"
+	SortCreatedAtDesc = "-created_at"
+)
+
+// PageQuery selects a page of a list ordered by (created_at, id), newest first unless Sort is SortCreatedAtAsc.
+// Cursor is the NextCursor of the previous page, empty for the first page.
+type PageQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=created_at -created_at"`
+}
+
+// Size is the number of items of the page, DefaultPageLimit if no limit was given.
+func (q *PageQuery) Size() int {
+	if q.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	if q.Limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return q.Limit
+}
+
+func (q *PageQuery) Descending() bool {
+	return q.Sort != SortCreatedAtAsc
+}
+
+// Page is the metadata of a page returned with its items.
+type Page struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Cursor is the position of a row in a list ordered by (created_at, id), the ID breaks ties of equal timestamps.
+// It is handed to clients opaque, as Encode returns it.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        uint      `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode, it returns nil for an empty cursor and ErrInvalidCursor
+// for one that was not.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,50 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := models.Cursor{CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456000, time.UTC), ID: 42}
+
+	decoded, err := models.DecodeCursor(cursor.Encode())
+	require.NoError(t, err)
+	assert.True(t, cursor.CreatedAt.Equal(decoded.CreatedAt))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestDecodeCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor string
+		err    error
+	}{
+		{"empty is the first page", "", nil},
+		{"not base64", "%%%", models.ErrInvalidCursor},
+		{"not json", "bm90IGpzb24", models.ErrInvalidCursor},
+		{"no id", models.Cursor{CreatedAt: time.Now()}.Encode(), models.ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := models.DecodeCursor(tt.cursor)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Nil(t, cursor)
+		})
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	assert.Equal(t, models.DefaultPageLimit, (&models.PageQuery{}).Size())
+	assert.Equal(t, 5, (&models.PageQuery{Limit: 5}).Size())
+	assert.Equal(t, models.MaxPageLimit, (&models.PageQuery{Limit: 1000}).Size())
+
+	assert.True(t, (&models.PageQuery{}).Descending(), "newest first by default")
+	assert.False(t, (&models.PageQuery{Sort: models.SortCreatedAtAsc}).Descending())
+}
@@ -1,31 +1,125 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
+	"payment-service/internal/currency"
+
 	"github.com/shopspring/decimal"
 )
 
 type PaymentStatus string
 
 const (
-	StatusPending   PaymentStatus = "pending"
-	StatusCompleted PaymentStatus = "completed"
-	StatusFailed    PaymentStatus = "failed"
+	StatusPending           PaymentStatus = "pending"
+	StatusCompleted         PaymentStatus = "completed"
+	StatusFailed            PaymentStatus = "failed"
+	StatusCancelled         PaymentStatus = "cancelled"          // cancelled by the client while pending, never debited
+	StatusPartiallyRefunded PaymentStatus = "partially_refunded" // completed, part of the amount was refunded
+	StatusRefunded          PaymentStatus = "refunded"           // completed, the whole amount was refunded
 )
 
 type Payment struct {
-	ID            uint            `json:"id" gorm:"primaryKey"`
-	UserID        string          `json:"user_id" gorm:"not null;index" binding:"required"`
+	ID            uint            `json:"id" gorm:"primaryKey;index:idx_payments_created_id,priority:2;index:idx_payments_user_created_id,priority:3;index:idx_payments_status_created_id,priority:3"`
+	UserID        string          `json:"user_id" gorm:"not null;index;index:idx_payments_user_created_id,priority:1" binding:"required"`
 	Amount        decimal.Decimal `json:"amount" gorm:"not null" binding:"required,decimalGt=0"`
+	Currency      string          `json:"currency" gorm:"size:3;not null;default:'USD'"`
 	TransactionID string          `json:"transaction_id" gorm:"unique;not null;index" binding:"required"`
-	Status        PaymentStatus   `json:"status" gorm:"default:pending"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
+	Status        PaymentStatus   `json:"status" gorm:"default:pending;index:idx_payments_status_created_id,priority:1"`
+	RequestHash   string          `json:"-" gorm:"not null;default:''"`
+	// FundsHeld is set when the amount was reserved in the wallet at creation, older payments have no hold
+	FundsHeld bool `json:"-" gorm:"not null;default:false"`
+	// Capturing is set under the row lock before the amount is captured at the processor,
+	// a payment being captured is charged and can no longer be cancelled
+	Capturing     bool   `json:"-" gorm:"not null;default:false"`
+	FailureReason string `json:"failure_reason,omitempty"`
+	// RefundedAmount is the sum of the refunds of the payment, it never exceeds the amount
+	RefundedAmount decimal.Decimal `json:"refunded_amount" gorm:"not null;default:0"`
+	// FX snapshot, written when the payment is created and never changed afterwards.
+	// The wallet in WalletCurrency is charged WalletAmount, the amount converted at FxRate (mid-market, wallet currency
+	// per unit of currency) with FxSpread on top. A payment in the wallet's currency has rate 1 and no spread,
+	// payments created before FX existed have no WalletCurrency and charge Amount in Currency.
+	WalletCurrency string          `json:"wallet_currency,omitempty" gorm:"size:3"`
+	WalletAmount   decimal.Decimal `json:"wallet_amount" gorm:"not null;default:0"`
+	FxRate         decimal.Decimal `json:"fx_rate" gorm:"not null;default:1"`
+	FxSpread       decimal.Decimal `json:"fx_spread" gorm:"not null;default:0"`
+	FxQuoteID      string          `json:"fx_quote_id,omitempty"`
+	// lists are ordered by (created_at, id), the composite indexes serve them alone and filtered by user or status
+	CreatedAt time.Time `json:"created_at" gorm:"index:idx_payments_created_id,priority:1;index:idx_payments_user_created_id,priority:2;index:idx_payments_status_created_id,priority:2"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type PaymentRequest struct {
 	UserID        string          `json:"user_id" binding:"required"`
-	Amount        decimal.Decimal `json:"amount" binding:"required,decimalGt=0"`
+	Amount        decimal.Decimal `json:"amount" binding:"required,decimalGt=0,decimalPrecision=Currency"`
+	Currency      string          `json:"currency" binding:"omitempty,currency"` // ISO-4217 code, the default currency if empty
 	TransactionID string          `json:"transaction_id" binding:"required"`
+	// WalletCurrency pays from the user's wallet in another currency, converted at the quote's rate if QuoteID is set
+	// and at the current rate otherwise
+	WalletCurrency string `json:"wallet_currency" binding:"omitempty,currency"`
+	QuoteID        string `json:"quote_id"`
+}
+
+// Hash returns a canonical hash of the request, used to detect a transaction_id that is reused with a different payload.
+// The amount is normalized first, so "100" and "100.00" hash the same.
+// The default currency is left out, so that the hashes of payments created before currencies existed still match.
+func (req *PaymentRequest) Hash() string {
+	hash := sha256.New()
+	hash.Write([]byte(req.UserID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Amount.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.TransactionID))
+	if code := currency.OrDefault(req.Currency); code != currency.Default {
+		hash.Write([]byte{0})
+		hash.Write([]byte(code))
+	}
+	if req.WalletCurrency != "" || req.QuoteID != "" {
+		hash.Write([]byte{0})
+		hash.Write([]byte(req.WalletCurrency))
+		hash.Write([]byte{0})
+		hash.Write([]byte(req.QuoteID))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ChargedCurrency is the currency of the wallet the payment is charged to.
+func (p *Payment) ChargedCurrency() string {
+	if p.WalletCurrency == "" {
+		return p.Currency
+	}
+	return p.WalletCurrency
+}
+
+// ChargedAmount is the amount the wallet is charged, in ChargedCurrency.
+func (p *Payment) ChargedAmount() decimal.Decimal {
+	if p.WalletCurrency == "" {
+		return p.Amount
+	}
+	return p.WalletAmount
+}
+
+// Refundable is the amount that can still be refunded, zero for a payment that did not complete.
+func (p *Payment) Refundable() decimal.Decimal {
+	if p.Status != StatusCompleted && p.Status != StatusPartiallyRefunded {
+		return decimal.Zero
+	}
+	return p.Amount.Sub(p.RefundedAmount)
+}
+
+// ChargedRefund is the part of ChargedAmount that goes back to the wallet for a refund of amount, in ChargedCurrency.
+// For a converted payment it is the share of the wallet amount at the payment's snapshot, rounded down against the
+// cumulative refunded amount, so the refunds of a fully refunded payment add up to exactly the wallet amount.
+func (p *Payment) ChargedRefund(amount decimal.Decimal) decimal.Decimal {
+	if p.WalletCurrency == "" || p.WalletCurrency == p.Currency {
+		return amount
+	}
+
+	places, _ := currency.MinorUnits(p.WalletCurrency)
+	share := func(refunded decimal.Decimal) decimal.Decimal {
+		return p.WalletAmount.Mul(refunded).Div(p.Amount).RoundFloor(places)
+	}
+	return share(p.RefundedAmount.Add(amount)).Sub(share(p.RefundedAmount))
 }
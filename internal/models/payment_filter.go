@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PaymentFilter narrows a list of payments, a zero field matches every payment.
+// Amounts are compared in the payment currency, the created range includes CreatedFrom and excludes CreatedTo.
+type PaymentFilter struct {
+	UserID      string           `form:"user_id"`
+	Status      PaymentStatus    `form:"status" binding:"omitempty,oneof=pending completed failed cancelled partially_refunded refunded"`
+	MinAmount   *decimal.Decimal `form:"min_amount"`
+	MaxAmount   *decimal.Decimal `form:"max_amount"`
+	CreatedFrom *time.Time       `form:"created_from"` // RFC 3339
+	CreatedTo   *time.Time       `form:"created_to"`   // RFC 3339
+}
+
+// PaymentListQuery is the query of GET /api/v1/payments
+type PaymentListQuery struct {
+	PaymentFilter
+	PageQuery
+}
+
+// PaymentExportQuery is the query of GET /api/v1/payments/export
+type PaymentExportQuery struct {
+	PaymentFilter
+	Format string `form:"format" binding:"required,oneof=csv ndjson"`
+	Gzip   bool   `form:"gzip"` // compress the export, as a .gz file
+}
+
+// UserPaymentQuery is the query of GET /api/v1/users/:userId/payments
+type UserPaymentQuery struct {
+	Status PaymentStatus `form:"status" binding:"omitempty,oneof=pending completed failed cancelled partially_refunded refunded"`
+	PageQuery
+}
+
+// SettledStatuses are the statuses of payments whose money moved, the amount less RefundedAmount was paid.
+var SettledStatuses = []PaymentStatus{StatusCompleted, StatusPartiallyRefunded, StatusRefunded}
+
+// UserPayment is a payment in the list of a user's payments.
+// RunningTotal is the money settled by the user's listed payments in the same currency, from the oldest up to and
+// including this one, whatever the sort order and the page. Pending, failed and cancelled payments add nothing,
+// refunds are deducted.
+type UserPayment struct {
+	Payment
+	RunningTotal decimal.Decimal `json:"running_total"`
+}
+
+// Cursor is the position of the payment in a list ordered by (created_at, id).
+func (p *Payment) Cursor() Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+type PaymentJobStatus string
+
+const (
+	JobQueued    PaymentJobStatus = "queued"
+	JobRunning   PaymentJobStatus = "running"
+	JobSucceeded PaymentJobStatus = "succeeded"
+	JobDead      PaymentJobStatus = "dead" // gave up after the last attempt, the payment was marked failed
+)
+
+// PaymentJob is a durable unit of work that drives a pending payment to a terminal status.
+// Jobs are claimed with SELECT ... FOR UPDATE SKIP LOCKED, so any number of workers can share the table.
+type PaymentJob struct {
+	ID        uint             `json:"id" gorm:"primaryKey"`
+	PaymentID uint             `json:"payment_id" gorm:"not null;uniqueIndex"`
+	Status    PaymentJobStatus `json:"status" gorm:"not null;default:queued;index:idx_payment_jobs_claim,priority:1"`
+	Attempts  int              `json:"attempts" gorm:"not null;default:0"`
+	RunAt     time.Time        `json:"run_at" gorm:"not null;index:idx_payment_jobs_claim,priority:2"`
+	LockedAt  *time.Time       `json:"locked_at"`
+	LastError string           `json:"last_error"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
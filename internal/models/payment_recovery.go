@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+type RecoveryAction string
+
+const (
+	RecoveryResumed RecoveryAction = "resumed" // a job was (re)queued to process the payment
+	RecoveryFailed  RecoveryAction = "failed"  // the payment was pending for too long and marked failed
+)
+
+// PaymentRecoveryAudit records a decision of the recovery sweep about a payment stuck in pending.
+type PaymentRecoveryAudit struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	PaymentID     uint           `json:"payment_id" gorm:"not null;index"`
+	TransactionID string         `json:"transaction_id" gorm:"not null"`
+	Action        RecoveryAction `json:"action" gorm:"not null"`
+	Reason        string         `json:"reason" gorm:"not null"`
+	PendingSince  time.Time      `json:"pending_since" gorm:"not null"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid payment status transition")
+	// ErrStatusChanged is returned by a guarded status update when the row is no longer in the status it was read in.
+	ErrStatusChanged = errors.New("status was changed concurrently")
+	// ErrPaymentCapturing is returned when giving up on a payment whose charge may already be captured at the processor.
+	ErrPaymentCapturing = errors.New("payment is being captured and cannot be failed")
+)
+
+// paymentTransitions lists the statuses a payment can move to from each status.
+// A status without transitions is final.
+var paymentTransitions = map[PaymentStatus][]PaymentStatus{
+	StatusPending:           {StatusCompleted, StatusFailed, StatusCancelled},
+	StatusCompleted:         {StatusPartiallyRefunded, StatusRefunded},
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+}
+
+// CanTransitionTo reports whether a payment in status s can move to status to.
+// A partially refunded payment can move to itself, every further partial refund is a transition.
+func (s PaymentStatus) CanTransitionTo(to PaymentStatus) bool {
+	for _, next := range paymentTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no transition leaves status s.
+func (s PaymentStatus) IsFinal() bool {
+	return len(paymentTransitions[s]) == 0
+}
+
+// TransitionTo moves the payment to status to, it fails with ErrInvalidTransition if the state machine does not allow it.
+func (p *Payment) TransitionTo(to PaymentStatus) error {
+	if !p.Status.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, p.Status, to)
+	}
+	p.Status = to
+	return nil
+}
+
+// PaymentStatusHistory records a status change of a payment. The creation of a payment is recorded with an empty FromStatus.
+type PaymentStatusHistory struct {
+	ID         uint          `json:"id" gorm:"primaryKey"`
+	PaymentID  uint          `json:"payment_id" gorm:"not null;index"`
+	FromStatus PaymentStatus `json:"from_status"`
+	ToStatus   PaymentStatus `json:"to_status" gorm:"not null"`
+	Reason     string        `json:"reason,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+}
+
+func (PaymentStatusHistory) TableName() string {
+	return "payment_status_history"
+}
@@ -0,0 +1,67 @@
+package models_test
+
+import (
+	"testing"
+
+	"payment-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentTransitionTo(t *testing.T) {
+	tests := []struct {
+		from    models.PaymentStatus
+		to      models.PaymentStatus
+		allowed bool
+	}{
+		{models.StatusPending, models.StatusCompleted, true},
+		{models.StatusPending, models.StatusFailed, true},
+		{models.StatusPending, models.StatusCancelled, true},
+		{models.StatusPending, models.StatusRefunded, false},
+		{models.StatusPending, models.StatusPending, false},
+		{models.StatusCompleted, models.StatusPartiallyRefunded, true},
+		{models.StatusCompleted, models.StatusRefunded, true},
+		{models.StatusCompleted, models.StatusFailed, false},
+		{models.StatusCompleted, models.StatusCancelled, false},
+		{models.StatusCompleted, models.StatusPending, false},
+		{models.StatusPartiallyRefunded, models.StatusPartiallyRefunded, true},
+		{models.StatusPartiallyRefunded, models.StatusRefunded, true},
+		{models.StatusPartiallyRefunded, models.StatusCompleted, false},
+		{models.StatusFailed, models.StatusCompleted, false},
+		{models.StatusFailed, models.StatusPending, false},
+		{models.StatusCancelled, models.StatusCompleted, false},
+		{models.StatusRefunded, models.StatusPartiallyRefunded, false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.from)+" to "+string(tt.to), func(t *testing.T) {
+			assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to))
+
+			payment := &models.Payment{Status: tt.from}
+			err := payment.TransitionTo(tt.to)
+			if tt.allowed {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.to, payment.Status)
+			} else {
+				assert.ErrorIs(t, err, models.ErrInvalidTransition)
+				assert.Equal(t, tt.from, payment.Status, "a rejected transition should leave the status unchanged")
+			}
+		})
+	}
+}
+
+func TestPaymentStatusIsFinal(t *testing.T) {
+	tests := []struct {
+		status models.PaymentStatus
+		final  bool
+	}{
+		{models.StatusPending, false},
+		{models.StatusCompleted, false},
+		{models.StatusPartiallyRefunded, false},
+		{models.StatusFailed, true},
+		{models.StatusCancelled, true},
+		{models.StatusRefunded, true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.final, tt.status.IsFinal(), string(tt.status))
+	}
+}
@@ -0,0 +1,41 @@
+package models_test
+
+import (
+	"testing"
+
+	"payment-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaymentChargedRefund(t *testing.T) {
+	converted := func(refunded string) *models.Payment {
+		return &models.Payment{
+			Amount:         d("10"),
+			Currency:       "EUR",
+			WalletCurrency: "USD",
+			WalletAmount:   d("10.86"),
+			RefundedAmount: d(refunded),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		payment  *models.Payment
+		amount   string
+		expected string
+	}{
+		{"not converted", &models.Payment{Amount: d("10"), Currency: "USD"}, "3.33", "3.33"},
+		{"same currency", &models.Payment{Amount: d("10"), Currency: "USD", WalletCurrency: "USD", WalletAmount: d("10")}, "3.33", "3.33"},
+		{"full refund", converted("0"), "10", "10.86"},
+		{"first share rounds down", converted("0"), "3.33", "3.61"},
+		{"second share", converted("3.33"), "3.33", "3.62"},
+		{"last share takes remainder", converted("6.66"), "3.34", "3.63"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, d(tt.expected).String(), tt.payment.ChargedRefund(d(tt.amount)).String())
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+type ProcessorEventType string
+
+const (
+	EventChargeSucceeded ProcessorEventType = "charge.succeeded"
+	EventChargeFailed    ProcessorEventType = "charge.failed"
+)
+
+// ProcessorEvent is an event received through the processor webhook.
+// Events are recorded by their event ID in the same transaction that applies them, so a redelivered event is applied once.
+type ProcessorEvent struct {
+	ID            uint               `json:"id" gorm:"primaryKey"`
+	EventID       string             `json:"event_id" gorm:"uniqueIndex;size:255;not null"`
+	Type          ProcessorEventType `json:"type" gorm:"not null"`
+	TransactionID string             `json:"transaction_id" gorm:"not null;index"`
+	PaymentID     uint               `json:"payment_id" gorm:"not null"`
+	Reason        string             `json:"reason,omitempty"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
+
+// ProcessorEventRequest is the payload of the processor webhook, the reference is the transaction ID of the payment.
+type ProcessorEventRequest struct {
+	EventID       string             `json:"event_id" binding:"required"`
+	Type          ProcessorEventType `json:"type" binding:"required,oneof=charge.succeeded charge.failed"`
+	Reference     string             `json:"reference" binding:"required"`
+	DeclineReason string             `json:"decline_reason"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Refund gives back part or all of a completed payment to the user's wallet.
+// The refund ID is chosen by the client and makes the request idempotent, like the transaction ID of a payment.
+type Refund struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	RefundID      string          `json:"refund_id" gorm:"uniqueIndex;size:255;not null"`
+	PaymentID     uint            `json:"payment_id" gorm:"not null;index"`
+	TransactionID string          `json:"transaction_id" gorm:"not null"`
+	Amount        decimal.Decimal `json:"amount" gorm:"not null"`
+	// WalletCurrency and WalletAmount are what went back to the wallet of a converted payment, see Payment.ChargedRefund
+	WalletCurrency string          `json:"wallet_currency,omitempty" gorm:"size:3"`
+	WalletAmount   decimal.Decimal `json:"wallet_amount" gorm:"default:0"`
+	Reason         string          `json:"reason,omitempty"`
+	RequestHash    string          `json:"-" gorm:"not null"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type RefundRequest struct {
+	RefundID string          `json:"refund_id" binding:"required"`
+	Amount   decimal.Decimal `json:"amount" binding:"required,decimalGt=0"`
+	Reason   string          `json:"reason"`
+}
+
+// Hash returns a canonical hash of the refund request for the payment, used to detect a refund_id that is reused
+// with a different payload.
+func (req *RefundRequest) Hash(transactionID string) string {
+	hash := sha256.New()
+	hash.Write([]byte(transactionID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.RefundID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Amount.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Reason))
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"payment-service/internal/currency"
+
+	"github.com/shopspring/decimal"
+)
+
+// Transfer moves money from one user's wallet to another's.
+// The transfer ID is chosen by the client and makes the request idempotent.
+type Transfer struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	TransferID  string          `json:"transfer_id" gorm:"uniqueIndex;size:255;not null"`
+	FromUserID  string          `json:"from_user_id" gorm:"not null;index"`
+	ToUserID    string          `json:"to_user_id" gorm:"not null;index"`
+	Amount      decimal.Decimal `json:"amount" gorm:"not null"`
+	Currency    string          `json:"currency" gorm:"size:3;not null;default:'USD'"`
+	Description string          `json:"description,omitempty"`
+	RequestHash string          `json:"-" gorm:"not null"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type TransferRequest struct {
+	TransferID  string          `json:"transfer_id" binding:"required"`
+	FromUserID  string          `json:"from_user_id" binding:"required"`
+	ToUserID    string          `json:"to_user_id" binding:"required,nefield=FromUserID"`
+	Amount      decimal.Decimal `json:"amount" binding:"required,decimalGt=0,decimalPrecision=Currency"`
+	Currency    string          `json:"currency" binding:"omitempty,currency"` // ISO-4217 code, the default currency if empty
+	Description string          `json:"description"`
+}
+
+// Hash returns a canonical hash of the request, used to detect a transfer_id that is reused with a different payload.
+func (req *TransferRequest) Hash() string {
+	hash := sha256.New()
+	hash.Write([]byte(req.TransferID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.FromUserID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.ToUserID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Amount.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.Description))
+	if code := currency.OrDefault(req.Currency); code != currency.Default {
+		hash.Write([]byte{0})
+		hash.Write([]byte(code))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -10,5 +10,7 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	Wallet *Wallet `gorm:"foreignKey:UserID;references:UserID"`
+	// Wallet is the wallet in the default currency
+	Wallet  *Wallet   `gorm:"-"`
+	Wallets []*Wallet `json:"wallets,omitempty" gorm:"foreignKey:UserID;references:UserID"`
 }
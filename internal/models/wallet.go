@@ -1,19 +1,105 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/shopspring/decimal"
 )
 
+var (
+	ErrInvalidAmount     = errors.New("amount must be greater than zero")
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrInsufficientHold  = errors.New("amount exceeds the held balance")
+)
+
 type Wallet struct {
-	ID        uint            `json:"id" gorm:"primaryKey"`
-	UserID    string          `json:"user_id" gorm:"not null;uniqueIndex"`
-	Balance   decimal.Decimal `json:"balance" gorm:"not null"`
-	CreatedAt time.Time       `json:"created_at"`
-	UpdatedAt time.Time       `json:"updated_at"`
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID string `json:"user_id" gorm:"not null;uniqueIndex:idx_wallets_user_currency"`
+	// Currency is the ISO-4217 code of the balance, a user has at most one wallet per currency
+	Currency string          `json:"currency" gorm:"size:3;not null;default:'USD';uniqueIndex:idx_wallets_user_currency"`
+	Balance  decimal.Decimal `json:"balance" gorm:"not null"`
+	// HeldBalance is the part of the balance reserved for pending payments, it cannot be spent otherwise
+	HeldBalance decimal.Decimal `json:"held_balance" gorm:"not null;default:0"`
+	// OverdraftLimit is how far the balance may go below zero, zero allows no overdraft
+	OverdraftLimit decimal.Decimal `json:"overdraft_limit" gorm:"not null;default:0"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+type WalletRequest struct {
+	Currency string `json:"currency" binding:"required,currency"`
+}
+
+// Available is the balance that is not held for pending payments.
+func (wallet *Wallet) Available() decimal.Decimal {
+	return wallet.Balance.Sub(wallet.HeldBalance)
+}
+
+// CanDebit checks that the amount can be taken from the available balance without going below the overdraft limit.
+func (wallet *Wallet) CanDebit(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	if wallet.Available().Sub(amount).LessThan(wallet.OverdraftLimit.Neg()) {
+		return ErrInsufficientFunds
+	}
+	return nil
 }
 
-func (wallet *Wallet) Credit(amount decimal.Decimal) {
+// Debit takes the amount from the wallet, the balance is left unchanged if it returns an error.
+func (wallet *Wallet) Debit(amount decimal.Decimal) error {
+	if err := wallet.CanDebit(amount); err != nil {
+		return err
+	}
 	wallet.Balance = wallet.Balance.Sub(amount)
+	return nil
+}
+
+// Credit adds the amount to the wallet, the balance is left unchanged if it returns an error.
+func (wallet *Wallet) Credit(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	wallet.Balance = wallet.Balance.Add(amount)
+	return nil
+}
+
+// Reserve holds the amount for a pending payment, it is no longer available but still part of the balance.
+// The held balance is left unchanged if it returns an error.
+func (wallet *Wallet) Reserve(amount decimal.Decimal) error {
+	if err := wallet.CanDebit(amount); err != nil {
+		return err
+	}
+	wallet.HeldBalance = wallet.HeldBalance.Add(amount)
+	return nil
+}
+
+// Capture takes a held amount from the wallet, the payment it was reserved for completed.
+func (wallet *Wallet) Capture(amount decimal.Decimal) error {
+	if err := wallet.checkHeld(amount); err != nil {
+		return err
+	}
+	wallet.HeldBalance = wallet.HeldBalance.Sub(amount)
+	wallet.Balance = wallet.Balance.Sub(amount)
+	return nil
+}
+
+// Release makes a held amount available again, the payment it was reserved for failed.
+func (wallet *Wallet) Release(amount decimal.Decimal) error {
+	if err := wallet.checkHeld(amount); err != nil {
+		return err
+	}
+	wallet.HeldBalance = wallet.HeldBalance.Sub(amount)
+	return nil
+}
+
+func (wallet *Wallet) checkHeld(amount decimal.Decimal) error {
+	if !amount.IsPositive() {
+		return ErrInvalidAmount
+	}
+	if amount.GreaterThan(wallet.HeldBalance) {
+		return ErrInsufficientHold
+	}
+	return nil
 }
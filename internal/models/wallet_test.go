@@ -0,0 +1,126 @@
+package models_test
+
+import (
+	"testing"
+
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func d(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestWalletDebit(t *testing.T) {
+	tests := []struct {
+		name      string
+		balance   string
+		overdraft string
+		amount    string
+		expected  string
+		err       error
+	}{
+		{"debit within balance", "100", "0", "40", "60", nil},
+		{"debit whole balance", "100", "0", "100", "0", nil},
+		{"debit fractions", "100.50", "0", "0.25", "100.25", nil},
+		{"debit beyond balance", "100", "0", "100.01", "100", models.ErrInsufficientFunds},
+		{"debit into overdraft", "100", "50", "150", "-50", nil},
+		{"debit beyond overdraft", "100", "50", "150.01", "100", models.ErrInsufficientFunds},
+		{"debit from overdrawn wallet", "-50", "50", "1", "-50", models.ErrInsufficientFunds},
+		{"zero amount", "100", "0", "0", "100", models.ErrInvalidAmount},
+		{"negative amount", "100", "0", "-10", "100", models.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := &models.Wallet{Balance: d(tt.balance), OverdraftLimit: d(tt.overdraft)}
+
+			err := wallet.Debit(d(tt.amount))
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.True(t, wallet.Balance.Equal(d(tt.expected)), "balance %s, expected %s", wallet.Balance, tt.expected)
+		})
+	}
+}
+
+func TestWalletCredit(t *testing.T) {
+	tests := []struct {
+		name     string
+		balance  string
+		amount   string
+		expected string
+		err      error
+	}{
+		{"credit", "100", "40", "140", nil},
+		{"credit fractions", "100", "0.01", "100.01", nil},
+		{"credit overdrawn wallet", "-50", "60", "10", nil},
+		{"zero amount", "100", "0", "100", models.ErrInvalidAmount},
+		{"negative amount", "100", "-10", "100", models.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := &models.Wallet{Balance: d(tt.balance)}
+
+			err := wallet.Credit(d(tt.amount))
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.True(t, wallet.Balance.Equal(d(tt.expected)), "balance %s, expected %s", wallet.Balance, tt.expected)
+		})
+	}
+}
+
+func TestWalletCanDebitLeavesBalance(t *testing.T) {
+	wallet := &models.Wallet{Balance: d("100")}
+
+	assert.NoError(t, wallet.CanDebit(d("100")))
+	assert.ErrorIs(t, wallet.CanDebit(d("101")), models.ErrInsufficientFunds)
+	assert.True(t, wallet.Balance.Equal(d("100")))
+}
+
+func TestWalletDebitRespectsHeldBalance(t *testing.T) {
+	wallet := &models.Wallet{Balance: d("100"), HeldBalance: d("70")}
+
+	assert.ErrorIs(t, wallet.Debit(d("31")), models.ErrInsufficientFunds, "held funds should not be spendable")
+	assert.NoError(t, wallet.Debit(d("30")))
+	assert.True(t, wallet.Balance.Equal(d("70")))
+	assert.True(t, wallet.Available().IsZero())
+}
+
+func TestWalletHold(t *testing.T) {
+	tests := []struct {
+		name            string
+		balance         string
+		held            string
+		operation       func(wallet *models.Wallet) error
+		expectedBalance string
+		expectedHeld    string
+		err             error
+	}{
+		{"reserve available funds", "100", "0", func(w *models.Wallet) error { return w.Reserve(d("60")) }, "100", "60", nil},
+		{"reserve the rest", "100", "60", func(w *models.Wallet) error { return w.Reserve(d("40")) }, "100", "100", nil},
+		{"reserve beyond available", "100", "60", func(w *models.Wallet) error { return w.Reserve(d("40.01")) }, "100", "60", models.ErrInsufficientFunds},
+		{"reserve zero", "100", "0", func(w *models.Wallet) error { return w.Reserve(d("0")) }, "100", "0", models.ErrInvalidAmount},
+		{"capture held funds", "100", "60", func(w *models.Wallet) error { return w.Capture(d("60")) }, "40", "0", nil},
+		{"capture part of the held funds", "100", "60", func(w *models.Wallet) error { return w.Capture(d("20")) }, "80", "40", nil},
+		{"capture beyond held", "100", "60", func(w *models.Wallet) error { return w.Capture(d("61")) }, "100", "60", models.ErrInsufficientHold},
+		{"capture negative", "100", "60", func(w *models.Wallet) error { return w.Capture(d("-1")) }, "100", "60", models.ErrInvalidAmount},
+		{"release held funds", "100", "60", func(w *models.Wallet) error { return w.Release(d("60")) }, "100", "0", nil},
+		{"release beyond held", "100", "60", func(w *models.Wallet) error { return w.Release(d("61")) }, "100", "60", models.ErrInsufficientHold},
+		{"release zero", "100", "60", func(w *models.Wallet) error { return w.Release(d("0")) }, "100", "60", models.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := &models.Wallet{Balance: d(tt.balance), HeldBalance: d(tt.held)}
+
+			err := tt.operation(wallet)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.True(t, wallet.Balance.Equal(d(tt.expectedBalance)), "balance %s, expected %s", wallet.Balance, tt.expectedBalance)
+			assert.True(t, wallet.HeldBalance.Equal(d(tt.expectedHeld)), "held %s, expected %s", wallet.HeldBalance, tt.expectedHeld)
+		})
+	}
+}
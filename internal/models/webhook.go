@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+)
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"   // waiting for its next attempt
+	DeliverySucceeded WebhookDeliveryStatus = "succeeded" // the endpoint answered 2xx
+	DeliveryFailed    WebhookDeliveryStatus = "failed"    // gave up after the last attempt, can be redelivered manually
+)
+
+// WebhookEndpoint is a merchant URL that receives every payment event.
+// The secret signs the events, it is only returned when the endpoint is registered.
+type WebhookEndpoint struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"secret,omitempty" gorm:"not null"`
+	Active    bool      `json:"active" gorm:"not null;default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookEndpointRequest struct {
+	URL string `json:"url" binding:"required,url"`
+}
+
+// WebhookDelivery is the delivery of one event to one endpoint, retried with exponential backoff.
+// The payload is the PaymentEvent JSON. Deliveries are claimed with SELECT ... FOR UPDATE SKIP LOCKED, like payment jobs.
+type WebhookDelivery struct {
+	ID             uint                  `json:"id" gorm:"primaryKey"`
+	EndpointID     uint                  `json:"endpoint_id" gorm:"not null;uniqueIndex:idx_webhook_deliveries_event,priority:1"`
+	EventID        string                `json:"event_id" gorm:"not null;uniqueIndex:idx_webhook_deliveries_event,priority:2"`
+	EventType      PaymentEventType      `json:"event_type" gorm:"not null"`
+	PaymentID      uint                  `json:"payment_id" gorm:"not null;index"`
+	Payload        []byte                `json:"-" gorm:"not null"`
+	Status         WebhookDeliveryStatus `json:"status" gorm:"not null;default:pending;index:idx_webhook_deliveries_claim,priority:1"`
+	Attempts       int                   `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at" gorm:"not null;index:idx_webhook_deliveries_claim,priority:2"`
+	LastStatusCode int                   `json:"last_status_code"`
+	LastError      string                `json:"last_error"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log,omitempty" gorm:"foreignKey:DeliveryID"`
+}
+
+// WebhookDeliveryAttempt is the delivery log, one row per HTTP request sent for a delivery.
+type WebhookDeliveryAttempt struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DeliveryID uint      `json:"delivery_id" gorm:"not null;index"`
+	Attempt    int       `json:"attempt" gorm:"not null"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Succeeded reports whether the endpoint accepted the event.
+func (a *WebhookDeliveryAttempt) Succeeded() bool {
+	return a.Error == "" && a.StatusCode >= 200 && a.StatusCode < 300
+}
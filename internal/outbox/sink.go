@@ -0,0 +1,155 @@
+// Package outbox contains the sinks the outbox relay publishes domain events to.
+// Delivery is at least once: a sink may receive the same event more than once, e.g. when the relay
+// crashed after publishing but before marking the event published. Every event carries a stable event ID
+// that consumers use to drop duplicates.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/utils/logger"
+	"payment-service/internal/webhook"
+)
+
+type Sink interface {
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+// record is the representation of an event written by the log and file sinks.
+type record struct {
+	EventID       string                  `json:"event_id"`
+	EventType     models.PaymentEventType `json:"event_type"`
+	AggregateType string                  `json:"aggregate_type"`
+	AggregateID   uint                    `json:"aggregate_id"`
+	CreatedAt     time.Time               `json:"created_at"`
+	Payload       json.RawMessage         `json:"payload"`
+}
+
+func newRecord(event *models.OutboxEvent) record {
+	return record{
+		EventID:       event.EventID,
+		EventType:     event.EventType,
+		AggregateType: event.AggregateType,
+		AggregateID:   event.AggregateID,
+		CreatedAt:     event.CreatedAt,
+		Payload:       event.Payload,
+	}
+}
+
+// LogSink writes every event to the application log.
+type LogSink struct {
+	logger logger.Logger
+}
+
+func NewLogSink() *LogSink {
+	return &LogSink{logger: logger.Logger{}}
+}
+
+func (s *LogSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	line, err := json.Marshal(newRecord(event))
+	if err != nil {
+		return err
+	}
+	s.logger.Info("[Outbox] " + string(line))
+	return nil
+}
+
+// FileSink appends every event as a JSON line to a file, the file is synced before Publish returns.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	line, err := json.Marshal(newRecord(event))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// HTTPSink posts the payload of every event to a URL. The event ID is sent in the X-Webhook-Id header,
+// and the request is signed like a merchant webhook if a secret is set.
+type HTTPSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewHTTPSink(url, secret string, timeout time.Duration) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(event.Payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, event.EventID)
+	if s.secret != "" {
+		timestamp := time.Now().Unix()
+		req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(webhook.SignatureHeader, webhook.Sign(s.secret, timestamp, event.Payload))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("sink %s responded %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
+
+// MultiSink publishes every event to all sinks. If one of them fails the event is failed, and all sinks
+// receive it again on the retry.
+type MultiSink []Sink
+
+func (m MultiSink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
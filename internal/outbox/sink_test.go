@@ -0,0 +1,107 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/outbox"
+	"payment-service/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEvent(id string) *models.OutboxEvent {
+	return &models.OutboxEvent{
+		EventID:       id,
+		AggregateType: "payment",
+		AggregateID:   1,
+		EventType:     models.EventPaymentCompleted,
+		Payload:       []byte(`{"id":"` + id + `","type":"payment.completed"}`),
+		CreatedAt:     time.Now(),
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := outbox.NewFileSink(path)
+	require.NoError(t, err)
+
+	assert.NoError(t, sink.Publish(context.Background(), newEvent("evt_1")))
+	assert.NoError(t, sink.Publish(context.Background(), newEvent("evt_2")))
+	assert.NoError(t, sink.Close())
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line struct {
+			EventID string          `json:"event_id"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		assert.Contains(t, string(line.Payload), line.EventID)
+		ids = append(ids, line.EventID)
+	}
+	assert.Equal(t, []string{"evt_1", "evt_2"}, ids)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var received []*http.Request
+	var bodies [][]byte
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := outbox.NewHTTPSink(server.URL, "secret", time.Second)
+	event := newEvent("evt_1")
+
+	assert.NoError(t, sink.Publish(context.Background(), event))
+	require.Len(t, received, 1)
+	assert.Equal(t, "evt_1", received[0].Header.Get(webhook.IDHeader))
+	assert.Equal(t, event.Payload, bodies[0])
+	assert.NoError(t, webhook.Verify("secret", received[0].Header.Get(webhook.SignatureHeader),
+		received[0].Header.Get(webhook.TimestampHeader), bodies[0], time.Now(), time.Minute))
+
+	status = http.StatusBadGateway
+	assert.Error(t, sink.Publish(context.Background(), event), "a non-2xx answer should fail the event")
+}
+
+type sinkFunc func(ctx context.Context, event *models.OutboxEvent) error
+
+func (f sinkFunc) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	return f(ctx, event)
+}
+
+func TestMultiSink(t *testing.T) {
+	var published []string
+	ok := sinkFunc(func(ctx context.Context, event *models.OutboxEvent) error {
+		published = append(published, event.EventID)
+		return nil
+	})
+	failing := sinkFunc(func(ctx context.Context, event *models.OutboxEvent) error {
+		return errors.New("sink down")
+	})
+
+	err := outbox.MultiSink{ok, failing, ok}.Publish(context.Background(), newEvent("evt_1"))
+	assert.EqualError(t, err, "sink down")
+	assert.Equal(t, []string{"evt_1", "evt_1"}, published, "the other sinks should still receive the event")
+}
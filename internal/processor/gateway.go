@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Charge statuses of the gateway API
+const (
+	ChargePending    = "pending"
+	ChargeAuthorized = "authorized"
+	ChargeDeclined   = "declined"
+	ChargeCaptured   = "captured"
+	ChargeVoided     = "voided"
+)
+
+var ErrGatewayPollTimeout = errors.New("gateway charge is still pending")
+
+// GatewayCharge is the charge resource of the gateway API.
+type GatewayCharge struct {
+	ID            string `json:"id"`
+	Reference     string `json:"reference"`
+	UserID        string `json:"user_id"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	Status        string `json:"status"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
+
+// GatewayError is returned for a non-2xx answer of the gateway.
+type GatewayError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *GatewayError) Error() string {
+	return fmt.Sprintf("gateway responded %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the same request may succeed later.
+func (e *GatewayError) Retryable() bool {
+	return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+}
+
+type GatewayOptions struct {
+	BaseURL        string
+	APIKey         string
+	RequestTimeout time.Duration // timeout of a single HTTP request
+	MaxRetries     int           // retries of a request after a network error, a timeout or a 5xx answer
+	RetryBackoff   time.Duration // delay before the first retry, doubled for every further retry
+	PollInterval   time.Duration // how often a pending charge is polled
+	PollTimeout    time.Duration // how long a pending charge is polled before giving up
+}
+
+func DefaultGatewayOptions(baseURL, apiKey string) GatewayOptions {
+	return GatewayOptions{
+		BaseURL:        baseURL,
+		APIKey:         apiKey,
+		RequestTimeout: 5 * time.Second,
+		MaxRetries:     3,
+		RetryBackoff:   200 * time.Millisecond,
+		PollInterval:   500 * time.Millisecond,
+		PollTimeout:    30 * time.Second,
+	}
+}
+
+// GatewayProcessor is a PaymentProcessor backed by an external payment gateway over HTTP.
+//
+//	POST /v1/charges              create a charge, idempotent by the Idempotency-Key header
+//	GET  /v1/charges/{id}         poll the status of a charge
+//	POST /v1/charges/{id}/capture capture an authorized charge
+//	POST /v1/charges/{id}/void    void an authorized charge
+//
+// The reference of the payment is sent as Idempotency-Key, so retries of any call are safe.
+type GatewayProcessor struct {
+	opts   GatewayOptions
+	client *http.Client
+}
+
+func NewGatewayProcessor(opts GatewayOptions) *GatewayProcessor {
+	return &GatewayProcessor{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.RequestTimeout},
+	}
+}
+
+// Authorize creates a charge and polls it until the gateway decided, or until the poll timeout.
+func (g *GatewayProcessor) Authorize(ctx context.Context, req ChargeRequest) (*Authorization, error) {
+	body, err := json.Marshal(map[string]string{
+		"reference": req.Reference,
+		"user_id":   req.UserID,
+		"amount":    req.Amount.String(),
+		"currency":  req.Currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err := g.do(ctx, http.MethodPost, "/v1/charges", req.Reference, body)
+	if err != nil {
+		return nil, err
+	}
+
+	charge, err = g.poll(ctx, charge)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &Authorization{ID: charge.ID, Reference: req.Reference}
+	switch charge.Status {
+	case ChargeDeclined:
+		auth.Status = AuthorizationDeclined
+		auth.DeclineReason = charge.DeclineReason
+	case ChargeAuthorized, ChargeCaptured:
+		auth.Status = AuthorizationApproved
+	default:
+		return nil, fmt.Errorf("unexpected gateway charge status %q", charge.Status)
+	}
+	return auth, nil
+}
+
+func (g *GatewayProcessor) Capture(ctx context.Context, auth *Authorization) error {
+	charge, err := g.do(ctx, http.MethodPost, "/v1/charges/"+auth.ID+"/capture", auth.Reference+":capture", nil)
+	if err != nil {
+		return err
+	}
+	if charge.Status != ChargeCaptured {
+		return fmt.Errorf("gateway charge %s is %s after capture", charge.ID, charge.Status)
+	}
+	return nil
+}
+
+func (g *GatewayProcessor) Void(ctx context.Context, auth *Authorization) error {
+	charge, err := g.do(ctx, http.MethodPost, "/v1/charges/"+auth.ID+"/void", auth.Reference+":void", nil)
+	if err != nil {
+		return err
+	}
+	if charge.Status != ChargeVoided {
+		return fmt.Errorf("gateway charge %s is %s after void", charge.ID, charge.Status)
+	}
+	return nil
+}
+
+func (g *GatewayProcessor) poll(ctx context.Context, charge *GatewayCharge) (*GatewayCharge, error) {
+	deadline := time.Now().Add(g.opts.PollTimeout)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for charge.Status == ChargePending {
+		if !time.Now().Before(deadline) {
+			return nil, ErrGatewayPollTimeout
+		}
+
+		timer.Reset(g.opts.PollInterval)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		var err error
+		if charge, err = g.do(ctx, http.MethodGet, "/v1/charges/"+charge.ID, "", nil); err != nil {
+			return nil, err
+		}
+	}
+	return charge, nil
+}
+
+// do sends a request and decodes the charge of the answer.
+// Network errors, timeouts and retryable answers are retried with exponential backoff.
+func (g *GatewayProcessor) do(ctx context.Context, method, path, idempotencyKey string, body []byte) (*GatewayCharge, error) {
+	backoff := g.opts.RetryBackoff
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for attempt := 0; ; attempt++ {
+		charge, err := g.send(ctx, method, path, idempotencyKey, body)
+		if err == nil {
+			return charge, nil
+		}
+
+		var gatewayErr *GatewayError
+		if errors.As(err, &gatewayErr) && !gatewayErr.Retryable() {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= g.opts.MaxRetries {
+			return nil, fmt.Errorf("gateway %s %s failed after %d attempts: %w", method, path, attempt+1, err)
+		}
+
+		timer.Reset(backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (g *GatewayProcessor) send(ctx context.Context, method, path, idempotencyKey string, body []byte) (*GatewayCharge, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(g.opts.BaseURL, "/")+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.opts.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &GatewayError{StatusCode: resp.StatusCode, Body: string(payload)}
+	}
+
+	var charge GatewayCharge
+	if err := json.Unmarshal(payload, &charge); err != nil {
+		return nil, fmt.Errorf("failed to decode gateway response: %w", err)
+	}
+	return &charge, nil
+}
@@ -0,0 +1,105 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-service/internal/processor"
+	"payment-service/internal/processor/gatewaystub"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGateway(t *testing.T) (*processor.GatewayProcessor, *gatewaystub.Stub) {
+	stub := gatewaystub.New()
+	stub.TimeoutDelay = time.Second
+	server := stub.Start()
+	t.Cleanup(server.Close)
+
+	return processor.NewGatewayProcessor(processor.GatewayOptions{
+		BaseURL:        server.URL,
+		APIKey:         "test-key",
+		RequestTimeout: 50 * time.Millisecond,
+		MaxRetries:     2,
+		RetryBackoff:   time.Millisecond,
+		PollInterval:   time.Millisecond,
+		PollTimeout:    time.Second,
+	}), stub
+}
+
+func TestGatewayProcessor(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name           string
+		script         []gatewaystub.Behavior
+		expectedStatus processor.AuthorizationStatus
+		expectedCalls  int
+	}{
+		{name: "approve", script: []gatewaystub.Behavior{gatewaystub.Approve}, expectedStatus: processor.AuthorizationApproved, expectedCalls: 1},
+		{name: "decline", script: []gatewaystub.Behavior{gatewaystub.Decline}, expectedStatus: processor.AuthorizationDeclined, expectedCalls: 1},
+		{name: "5xx is retried", script: []gatewaystub.Behavior{gatewaystub.ServerError, gatewaystub.Approve}, expectedStatus: processor.AuthorizationApproved, expectedCalls: 2},
+		{name: "timeout is retried", script: []gatewaystub.Behavior{gatewaystub.Timeout, gatewaystub.Approve}, expectedStatus: processor.AuthorizationApproved, expectedCalls: 2},
+		{name: "pending charge is polled", script: []gatewaystub.Behavior{gatewaystub.Pending}, expectedStatus: processor.AuthorizationApproved, expectedCalls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway, stub := newGateway(t)
+			stub.Script(gatewaystub.OpCreateCharge, tt.script...)
+
+			auth, err := gateway.Authorize(ctx, charge("tx123"))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, auth.Status)
+			assert.Equal(t, tt.expectedCalls, stub.Calls(gatewaystub.OpCreateCharge))
+		})
+	}
+}
+
+func TestGatewayProcessorGivesUpAfterRetries(t *testing.T) {
+	gateway, stub := newGateway(t)
+	stub.SetDefault(gatewaystub.ServerError)
+
+	_, err := gateway.Authorize(context.Background(), charge("tx123"))
+	assert.Error(t, err)
+	assert.Equal(t, 3, stub.Calls(gatewaystub.OpCreateCharge), "first attempt and 2 retries")
+}
+
+func TestGatewayProcessorIsIdempotentPerReference(t *testing.T) {
+	ctx := context.Background()
+	gateway, stub := newGateway(t)
+
+	first, err := gateway.Authorize(ctx, charge("tx123"))
+	require.NoError(t, err)
+
+	// a retried authorization after an unknown outcome must not create a second charge
+	again, err := gateway.Authorize(ctx, charge("tx123"))
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.Equal(t, 2, stub.Calls(gatewaystub.OpCreateCharge))
+}
+
+func TestGatewayProcessorCaptureAndVoid(t *testing.T) {
+	ctx := context.Background()
+	gateway, stub := newGateway(t)
+
+	auth, err := gateway.Authorize(ctx, charge("tx123"))
+	require.NoError(t, err)
+
+	stub.Script(gatewaystub.OpCapture, gatewaystub.ServerError)
+	require.NoError(t, gateway.Capture(ctx, auth))
+	captured, _ := stub.Charge("tx123")
+	assert.Equal(t, processor.ChargeCaptured, captured.Status)
+	assert.Equal(t, "100", captured.Amount)
+	assert.Equal(t, "USD", captured.Currency)
+
+	assert.Error(t, gateway.Void(ctx, auth), "captured charge cannot be voided")
+
+	voidable, err := gateway.Authorize(ctx, charge("tx456"))
+	require.NoError(t, err)
+	require.NoError(t, gateway.Void(ctx, voidable))
+	voided, _ := stub.Charge("tx456")
+	assert.Equal(t, processor.ChargeVoided, voided.Status)
+}
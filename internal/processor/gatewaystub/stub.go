@@ -0,0 +1,257 @@
+// Package gatewaystub is a scriptable fake of the payment gateway API spoken by processor.GatewayProcessor.
+// It keeps charges in memory, and every endpoint can be scripted to approve, decline, hang past the
+// client timeout or answer with a server error, to test the payment lifecycle against network failures.
+package gatewaystub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"payment-service/internal/processor"
+)
+
+type Operation string
+
+const (
+	OpCreateCharge Operation = "create_charge"
+	OpGetCharge    Operation = "get_charge"
+	OpCapture      Operation = "capture"
+	OpVoid         Operation = "void"
+)
+
+type Behavior string
+
+const (
+	// Approve answers normally, a new charge is authorized
+	Approve Behavior = "approve"
+	// Decline answers normally, a new charge is declined
+	Decline Behavior = "decline"
+	// Pending creates a charge that stays pending for PendingPolls polls before it is authorized
+	Pending Behavior = "pending"
+	// Timeout does not answer until TimeoutDelay is over or the client gave up, nothing is changed
+	Timeout Behavior = "timeout"
+	// ServerError answers 503, nothing is changed
+	ServerError Behavior = "server_error"
+)
+
+// Stub is the fake gateway. Behaviors are consumed per operation in the order they were scripted,
+// an operation without a scripted behavior uses the default behavior.
+type Stub struct {
+	mu       sync.Mutex
+	nextID   int
+	fallback Behavior
+	script   map[Operation][]Behavior
+	calls    map[Operation]int
+	// key: charge id
+	charges      map[string]*processor.GatewayCharge
+	pendingPolls map[string]int
+	// key: reference, charges are idempotent by reference
+	byReference map[string]string
+
+	// TimeoutDelay is how long a Timeout behavior hangs, it should be longer than the client timeout
+	TimeoutDelay time.Duration
+	// PendingPolls is how many polls a Pending charge stays pending
+	PendingPolls int
+}
+
+func New() *Stub {
+	return &Stub{
+		fallback:     Approve,
+		script:       make(map[Operation][]Behavior),
+		calls:        make(map[Operation]int),
+		charges:      make(map[string]*processor.GatewayCharge),
+		pendingPolls: make(map[string]int),
+		byReference:  make(map[string]string),
+		TimeoutDelay: 10 * time.Second,
+		PendingPolls: 2,
+	}
+}
+
+// SetDefault sets the behavior of operations without a scripted behavior.
+func (s *Stub) SetDefault(behavior Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = behavior
+}
+
+// Script queues behaviors for the next calls of the operation.
+func (s *Stub) Script(op Operation, behaviors ...Behavior) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script[op] = append(s.script[op], behaviors...)
+}
+
+// Calls returns how many requests the operation received, including failed ones.
+func (s *Stub) Calls(op Operation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+// Charge returns the charge created for a reference.
+func (s *Stub) Charge(reference string) (processor.GatewayCharge, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	charge, ok := s.charges[s.byReference[reference]]
+	if !ok {
+		return processor.GatewayCharge{}, false
+	}
+	return *charge, true
+}
+
+// Start serves the stub on a local httptest server, the caller closes it.
+func (s *Stub) Start() *httptest.Server {
+	return httptest.NewServer(s.Handler())
+}
+
+func (s *Stub) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/charges", s.createCharge)
+	mux.HandleFunc("GET /v1/charges/{id}", s.getCharge)
+	mux.HandleFunc("POST /v1/charges/{id}/capture", s.captureCharge)
+	mux.HandleFunc("POST /v1/charges/{id}/void", s.voidCharge)
+	return mux
+}
+
+// next consumes the behavior of the next call of the operation.
+func (s *Stub) next(op Operation) Behavior {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[op]++
+	if queue := s.script[op]; len(queue) > 0 {
+		s.script[op] = queue[1:]
+		return queue[0]
+	}
+	return s.fallback
+}
+
+// fail applies the failure behaviors, it reports whether the request was answered.
+func (s *Stub) fail(w http.ResponseWriter, r *http.Request, behavior Behavior) bool {
+	switch behavior {
+	case Timeout:
+		select {
+		case <-r.Context().Done():
+		case <-time.After(s.TimeoutDelay):
+		}
+		w.WriteHeader(http.StatusGatewayTimeout)
+		return true
+	case ServerError:
+		http.Error(w, `{"error":"service unavailable"}`, http.StatusServiceUnavailable)
+		return true
+	}
+	return false
+}
+
+func (s *Stub) createCharge(w http.ResponseWriter, r *http.Request) {
+	behavior := s.next(OpCreateCharge)
+	if s.fail(w, r, behavior) {
+		return
+	}
+
+	var req struct {
+		Reference string `json:"reference"`
+		UserID    string `json:"user_id"`
+		Amount    string `json:"amount"`
+		Currency  string `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reference == "" {
+		http.Error(w, `{"error":"invalid charge"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.byReference[req.Reference]; ok {
+		writeCharge(w, http.StatusOK, s.charges[id])
+		return
+	}
+
+	s.nextID++
+	charge := &processor.GatewayCharge{
+		ID:        fmt.Sprintf("ch_%d", s.nextID),
+		Reference: req.Reference,
+		UserID:    req.UserID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Status:    processor.ChargeAuthorized,
+	}
+	switch behavior {
+	case Decline:
+		charge.Status = processor.ChargeDeclined
+		charge.DeclineReason = "card declined"
+	case Pending:
+		charge.Status = processor.ChargePending
+		s.pendingPolls[charge.ID] = s.PendingPolls
+	}
+
+	s.charges[charge.ID] = charge
+	s.byReference[req.Reference] = charge.ID
+	writeCharge(w, http.StatusCreated, charge)
+}
+
+func (s *Stub) getCharge(w http.ResponseWriter, r *http.Request) {
+	if s.fail(w, r, s.next(OpGetCharge)) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	charge, ok := s.charges[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"error":"charge not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if charge.Status == processor.ChargePending {
+		s.pendingPolls[charge.ID]--
+		if s.pendingPolls[charge.ID] <= 0 {
+			charge.Status = processor.ChargeAuthorized
+		}
+	}
+	writeCharge(w, http.StatusOK, charge)
+}
+
+func (s *Stub) captureCharge(w http.ResponseWriter, r *http.Request) {
+	s.transition(w, r, OpCapture, processor.ChargeCaptured)
+}
+
+func (s *Stub) voidCharge(w http.ResponseWriter, r *http.Request) {
+	s.transition(w, r, OpVoid, processor.ChargeVoided)
+}
+
+// transition moves an authorized charge to the target status, repeating it is a no-op.
+func (s *Stub) transition(w http.ResponseWriter, r *http.Request, op Operation, target string) {
+	if s.fail(w, r, s.next(op)) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	charge, ok := s.charges[r.PathValue("id")]
+	if !ok {
+		http.Error(w, `{"error":"charge not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if charge.Status != target && charge.Status != processor.ChargeAuthorized {
+		http.Error(w, fmt.Sprintf(`{"error":"charge is %s"}`, charge.Status), http.StatusConflict)
+		return
+	}
+
+	charge.Status = target
+	writeCharge(w, http.StatusOK, charge)
+}
+
+func writeCharge(w http.ResponseWriter, statusCode int, charge *processor.GatewayCharge) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(charge)
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrAuthorizationNotFound = errors.New("authorization not found")
+
+// ChargeRequest is what a processor needs to know about a payment.
+// Reference is the idempotency key towards the processor, calls with the same reference
+// refer to the same charge, so a retried call never charges twice.
+// Amount is in Currency, the currency the user's wallet is charged in.
+type ChargeRequest struct {
+	Reference string
+	UserID    string
+	Amount    decimal.Decimal
+	Currency  string
+}
+
+type AuthorizationStatus string
+
+const (
+	AuthorizationApproved AuthorizationStatus = "approved"
+	AuthorizationDeclined AuthorizationStatus = "declined"
+)
+
+// Authorization is the processor's answer to an authorization request.
+// A declined authorization is a final answer, not an error, DeclineReason tells why.
+type Authorization struct {
+	ID            string
+	Reference     string
+	Status        AuthorizationStatus
+	DeclineReason string
+}
+
+func (a *Authorization) Approved() bool {
+	return a.Status == AuthorizationApproved
+}
+
+// PaymentProcessor moves the money of a payment.
+// Authorize reserves the amount, Capture collects an approved authorization and Void releases it.
+// All calls are idempotent per reference. A returned error means the outcome is unknown
+// (network failure, timeout, processor error) and the call can be retried.
+type PaymentProcessor interface {
+	Authorize(ctx context.Context, req ChargeRequest) (*Authorization, error)
+	Capture(ctx context.Context, auth *Authorization) error
+	Void(ctx context.Context, auth *Authorization) error
+}
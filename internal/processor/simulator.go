@@ -0,0 +1,154 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type SimulatorOptions struct {
+	Seed        int64         // seed of the random outcomes and latencies, 0 seeds from the clock
+	SuccessRate float64       // share of approved authorizations, between 0 and 1
+	MinLatency  time.Duration // latency of every call is uniformly distributed between MinLatency and MaxLatency
+	MaxLatency  time.Duration
+}
+
+// DefaultSimulatorOptions approves 90% of payments within 1 to 3 seconds.
+func DefaultSimulatorOptions() SimulatorOptions {
+	return SimulatorOptions{
+		SuccessRate: 0.9,
+		MinLatency:  1 * time.Second,
+		MaxLatency:  3 * time.Second,
+	}
+}
+
+// Simulator is a PaymentProcessor for development and tests, nothing leaves the process.
+// Outcomes are random but reproducible with a fixed seed.
+type Simulator struct {
+	mu     sync.Mutex
+	opts   SimulatorOptions
+	rng    *rand.Rand
+	nextID int
+	// key: reference, keeps authorizations for idempotent retries
+	authorizations map[string]*Authorization
+	captured       map[string]bool
+	voided         map[string]bool
+}
+
+func NewSimulator(opts SimulatorOptions) *Simulator {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	return &Simulator{
+		opts:           opts,
+		rng:            rand.New(rand.NewSource(seed)),
+		authorizations: make(map[string]*Authorization),
+		captured:       make(map[string]bool),
+		voided:         make(map[string]bool),
+	}
+}
+
+func (s *Simulator) Authorize(ctx context.Context, req ChargeRequest) (*Authorization, error) {
+	if err := s.sleep(ctx); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if auth, ok := s.authorizations[req.Reference]; ok {
+		return auth, nil
+	}
+
+	s.nextID++
+	auth := &Authorization{
+		ID:        fmt.Sprintf("sim_auth_%d", s.nextID),
+		Reference: req.Reference,
+		Status:    AuthorizationApproved,
+	}
+	if s.rng.Float64() >= s.opts.SuccessRate {
+		auth.Status = AuthorizationDeclined
+		auth.DeclineReason = "declined by processor"
+	}
+
+	s.authorizations[req.Reference] = auth
+	return auth, nil
+}
+
+func (s *Simulator) Capture(ctx context.Context, auth *Authorization) error {
+	if err := s.sleep(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.authorizations[auth.Reference]
+	if !ok || !stored.Approved() {
+		return ErrAuthorizationNotFound
+	}
+	if s.voided[auth.Reference] {
+		return fmt.Errorf("authorization %s was voided", auth.ID)
+	}
+
+	s.captured[auth.Reference] = true
+	return nil
+}
+
+func (s *Simulator) Void(ctx context.Context, auth *Authorization) error {
+	if err := s.sleep(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.authorizations[auth.Reference]; !ok {
+		return ErrAuthorizationNotFound
+	}
+
+	s.voided[auth.Reference] = true
+	return nil
+}
+
+// Captured reports whether the charge with the reference was captured, for tests.
+func (s *Simulator) Captured(reference string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.captured[reference]
+}
+
+// Voided reports whether the charge with the reference was voided, for tests.
+func (s *Simulator) Voided(reference string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.voided[reference]
+}
+
+// sleep waits for a latency drawn from the configured distribution.
+func (s *Simulator) sleep(ctx context.Context) error {
+	s.mu.Lock()
+	latency := s.opts.MinLatency
+	if spread := s.opts.MaxLatency - s.opts.MinLatency; spread > 0 {
+		latency += time.Duration(s.rng.Int63n(int64(spread) + 1))
+	}
+	s.mu.Unlock()
+
+	if latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
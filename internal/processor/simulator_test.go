@@ -0,0 +1,109 @@
+package processor_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-service/internal/processor"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func charge(reference string) processor.ChargeRequest {
+	return processor.ChargeRequest{Reference: reference, UserID: "u1", Amount: decimal.NewFromInt(100), Currency: "USD"}
+}
+
+func outcomes(t *testing.T, sim *processor.Simulator, n int) []processor.AuthorizationStatus {
+	statuses := make([]processor.AuthorizationStatus, n)
+	for i := range statuses {
+		auth, err := sim.Authorize(context.Background(), charge(fmt.Sprintf("tx%d", i)))
+		require.NoError(t, err)
+		statuses[i] = auth.Status
+	}
+	return statuses
+}
+
+func TestSimulatorIsDeterministicWithSeed(t *testing.T) {
+	opts := processor.SimulatorOptions{Seed: 42, SuccessRate: 0.5}
+	first := outcomes(t, processor.NewSimulator(opts), 50)
+	second := outcomes(t, processor.NewSimulator(opts), 50)
+
+	assert.Equal(t, first, second, "same seed should produce the same outcomes")
+	assert.Contains(t, first, processor.AuthorizationApproved)
+	assert.Contains(t, first, processor.AuthorizationDeclined)
+}
+
+func TestSimulatorSuccessRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		successRate float64
+		expected    processor.AuthorizationStatus
+	}{
+		{name: "always approve", successRate: 1, expected: processor.AuthorizationApproved},
+		{name: "always decline", successRate: 0, expected: processor.AuthorizationDeclined},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := processor.NewSimulator(processor.SimulatorOptions{Seed: 1, SuccessRate: tt.successRate})
+			for _, status := range outcomes(t, sim, 20) {
+				assert.Equal(t, tt.expected, status)
+			}
+		})
+	}
+}
+
+func TestSimulatorIsIdempotentPerReference(t *testing.T) {
+	ctx := context.Background()
+	sim := processor.NewSimulator(processor.SimulatorOptions{Seed: 7, SuccessRate: 0.5})
+
+	first, err := sim.Authorize(ctx, charge("tx123"))
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		again, err := sim.Authorize(ctx, charge("tx123"))
+		require.NoError(t, err)
+		assert.Equal(t, first, again, "retried authorization should return the same answer")
+	}
+}
+
+func TestSimulatorCaptureAndVoid(t *testing.T) {
+	ctx := context.Background()
+	sim := processor.NewSimulator(processor.SimulatorOptions{Seed: 1, SuccessRate: 1})
+
+	auth, err := sim.Authorize(ctx, charge("tx123"))
+	require.NoError(t, err)
+	assert.NoError(t, sim.Capture(ctx, auth))
+	assert.True(t, sim.Captured("tx123"))
+
+	voided, err := sim.Authorize(ctx, charge("tx456"))
+	require.NoError(t, err)
+	assert.NoError(t, sim.Void(ctx, voided))
+	assert.Error(t, sim.Capture(ctx, voided), "voided authorization should not be captured")
+
+	assert.ErrorIs(t, sim.Capture(ctx, &processor.Authorization{Reference: "unknown"}), processor.ErrAuthorizationNotFound)
+}
+
+func TestSimulatorLatency(t *testing.T) {
+	sim := processor.NewSimulator(processor.SimulatorOptions{
+		Seed:        1,
+		SuccessRate: 1,
+		MinLatency:  20 * time.Millisecond,
+		MaxLatency:  40 * time.Millisecond,
+	})
+
+	start := time.Now()
+	_, err := sim.Authorize(context.Background(), charge("tx123"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	t.Run("Cancelled context should interrupt the latency", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := sim.Authorize(ctx, charge("tx456"))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,30 @@
+package processor
+
+import (
+	"time"
+
+	"payment-service/internal/webhook"
+)
+
+// Headers of a processor webhook request
+const (
+	WebhookSignatureHeader = "X-Processor-Signature"
+	WebhookTimestampHeader = "X-Processor-Timestamp"
+)
+
+var (
+	ErrWebhookSecretMissing    = webhook.ErrSecretMissing
+	ErrWebhookSignatureInvalid = webhook.ErrSignatureInvalid
+	ErrWebhookTimestampInvalid = webhook.ErrTimestampInvalid
+)
+
+// SignWebhook returns the signature the processor sends for a webhook body, see package webhook.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	return webhook.Sign(secret, timestamp, body)
+}
+
+// VerifyWebhook checks the signature and the timestamp header values of a processor webhook request.
+// The timestamp must not be further than tolerance away from now.
+func VerifyWebhook(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	return webhook.Verify(secret, signature, timestamp, body, now, tolerance)
+}
@@ -0,0 +1,47 @@
+package processor_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"payment-service/internal/processor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"event_id":"evt_1","type":"charge.succeeded","reference":"tx123"}`)
+	now := time.Unix(1_700_000_000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := processor.SignWebhook(secret, now.Unix(), body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		signature string
+		timestamp string
+		body      []byte
+		now       time.Time
+		expected  error
+	}{
+		{"valid", secret, signature, timestamp, body, now, nil},
+		{"valid within tolerance", secret, signature, timestamp, body, now.Add(4 * time.Minute), nil},
+		{"secret not configured", "", signature, timestamp, body, now, processor.ErrWebhookSecretMissing},
+		{"wrong secret", "other", signature, timestamp, body, now, processor.ErrWebhookSignatureInvalid},
+		{"tampered body", secret, signature, timestamp, []byte(`{"event_id":"evt_1","type":"charge.failed","reference":"tx123"}`), now, processor.ErrWebhookSignatureInvalid},
+		{"tampered timestamp", secret, signature, strconv.FormatInt(now.Unix()+1, 10), body, now, processor.ErrWebhookSignatureInvalid},
+		{"missing signature", secret, "", timestamp, body, now, processor.ErrWebhookSignatureInvalid},
+		{"missing timestamp", secret, signature, "", body, now, processor.ErrWebhookTimestampInvalid},
+		{"replayed too late", secret, signature, timestamp, body, now.Add(6 * time.Minute), processor.ErrWebhookTimestampInvalid},
+		{"timestamp in the future", secret, signature, timestamp, body, now.Add(-6 * time.Minute), processor.ErrWebhookTimestampInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := processor.VerifyWebhook(tt.secret, tt.signature, tt.timestamp, tt.body, tt.now, 5*time.Minute)
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+}
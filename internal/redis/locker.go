@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultLockTTL bounds how long a lock survives a holder that never releases it
+	DefaultLockTTL = 30 * time.Second
+	// DefaultLockWait is how long Acquire waits for a held lock before giving up
+	DefaultLockWait = 100 * time.Millisecond
+)
+
+var (
+	ErrLockNotAcquired = errors.New("lock not acquired")
+	ErrLockNotHeld     = errors.New("lock is not held by this owner")
+)
+
+// Lock is a successfully acquired lock.
+// The token identifies the owner on release. The fence is a fencing token that increases with
+// every acquisition, writes guarded by the lock pass it to storage, so that a holder whose lease
+// expired cannot overwrite the work of the next holder.
+type Lock struct {
+	Key       string
+	Token     string
+	Fence     uint64
+	ExpiresAt time.Time
+}
+
+type LockOptions struct {
+	TTL  time.Duration // a lock is released automatically after the TTL
+	Wait time.Duration // wait budget of Acquire for a lock held by someone else
+}
+
+func DefaultLockOptions() LockOptions {
+	return LockOptions{TTL: DefaultLockTTL, Wait: DefaultLockWait}
+}
+
+// Locker serializes work on an idempotency key.
+// Acquire waits for a held lock until the wait budget or ctx is over, whichever comes first,
+// and returns ErrLockNotAcquired or the ctx error respectively. It never leaves anything running
+// in the background after it returns.
+// A lock is released automatically when its TTL is over, Unlock returns ErrLockNotHeld in that case.
+type Locker interface {
+	Acquire(ctx context.Context, key string) (*Lock, error)
+	Unlock(ctx context.Context, lock *Lock) error
+	Metrics() LockMetrics
+}
+
+// LockMetrics is a snapshot of the lock-wait counters of a Locker.
+type LockMetrics struct {
+	Acquired    uint64 `json:"acquired"`      // successful acquisitions
+	Contended   uint64 `json:"contended"`     // acquisitions that found the lock held and had to wait
+	TimedOut    uint64 `json:"timed_out"`     // gave up after the wait budget
+	Cancelled   uint64 `json:"cancelled"`     // gave up because ctx was done
+	TotalWaitMs int64  `json:"total_wait_ms"` // time spent waiting by contended acquisitions
+	MaxWaitMs   int64  `json:"max_wait_ms"`   // longest single wait
+	Waiting     int64  `json:"waiting"`       // acquisitions waiting right now
+}
+
+// lockStats collects LockMetrics, it is safe for concurrent use.
+type lockStats struct {
+	acquired  atomic.Uint64
+	contended atomic.Uint64
+	timedOut  atomic.Uint64
+	cancelled atomic.Uint64
+	totalWait atomic.Int64
+	maxWait   atomic.Int64
+	waiting   atomic.Int64
+}
+
+func (s *lockStats) startWait() time.Time {
+	s.contended.Add(1)
+	s.waiting.Add(1)
+	return time.Now()
+}
+
+// endWait records a finished wait, err is the result of the acquisition.
+func (s *lockStats) endWait(start time.Time, err error) {
+	s.waiting.Add(-1)
+
+	waited := time.Since(start).Nanoseconds()
+	s.totalWait.Add(waited)
+	for {
+		current := s.maxWait.Load()
+		if waited <= current || s.maxWait.CompareAndSwap(current, waited) {
+			break
+		}
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrLockNotAcquired):
+		s.timedOut.Add(1)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		s.cancelled.Add(1)
+	}
+}
+
+func (s *lockStats) snapshot() LockMetrics {
+	return LockMetrics{
+		Acquired:    s.acquired.Load(),
+		Contended:   s.contended.Load(),
+		TimedOut:    s.timedOut.Load(),
+		Cancelled:   s.cancelled.Load(),
+		TotalWaitMs: time.Duration(s.totalWait.Load()).Milliseconds(),
+		MaxWaitMs:   time.Duration(s.maxWait.Load()).Milliseconds(),
+		Waiting:     s.waiting.Load(),
+	}
+}
+
+// waitDeadline is the end of the wait budget, shortened by the deadline of ctx if it has one.
+// It also returns the error to report when the deadline is reached.
+func waitDeadline(ctx context.Context, wait time.Duration) (time.Time, error) {
+	deadline := time.Now().Add(wait)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		return ctxDeadline, context.DeadlineExceeded
+	}
+	return deadline, ErrLockNotAcquired
+}
@@ -0,0 +1,146 @@
+package redis_test
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-service/internal/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockManagerAcquireHonorsWaitBudget(t *testing.T) {
+	ctx := context.Background()
+	lm := redis.NewLockManager(redis.LockOptions{TTL: time.Minute, Wait: 50 * time.Millisecond})
+
+	held, err := lm.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = lm.Acquire(ctx, "tx123")
+	assert.ErrorIs(t, err, redis.ErrLockNotAcquired)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Second)
+
+	t.Run("Waiter should get the lock as soon as it is released", func(t *testing.T) {
+		lm := redis.NewLockManager(redis.LockOptions{TTL: time.Minute, Wait: time.Second})
+		held, err := lm.Acquire(ctx, "tx123")
+		require.NoError(t, err)
+
+		time.AfterFunc(20*time.Millisecond, func() { _ = lm.Unlock(ctx, held) })
+		start := time.Now()
+		lock, err := lm.Acquire(ctx, "tx123")
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.NoError(t, lm.Unlock(ctx, lock))
+	})
+
+	t.Run("Context deadline shorter than the budget wins", func(t *testing.T) {
+		lm := redis.NewLockManager(redis.LockOptions{TTL: time.Minute, Wait: time.Minute})
+		_, err := lm.Acquire(ctx, "tx123")
+		require.NoError(t, err)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+		defer cancel()
+		_, err = lm.Acquire(timeoutCtx, "tx123")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, uint64(1), lm.Metrics().Cancelled)
+	})
+
+	assert.NoError(t, lm.Unlock(ctx, held))
+	metrics := lm.Metrics()
+	assert.Equal(t, uint64(1), metrics.Acquired)
+	assert.Equal(t, uint64(1), metrics.Contended)
+	assert.Equal(t, uint64(1), metrics.TimedOut)
+	assert.Equal(t, int64(0), metrics.Waiting)
+}
+
+func TestRedisLockerAcquireHonorsContext(t *testing.T) {
+	ctx := context.Background()
+	locker, _ := newRedisLocker(t, time.Minute)
+
+	_, err := locker.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = locker.Acquire(cancelCtx, "tx123")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, uint64(1), locker.Metrics().Cancelled)
+}
+
+// TestLockManagerStress hammers a few keys from many goroutines with short budgets and cancelled contexts.
+// Run with -race. It checks mutual exclusion, that every attempt is accounted for in the metrics,
+// that no key is left poisoned and that no goroutine outlives the test.
+func TestLockManagerStress(t *testing.T) {
+	const (
+		workers  = 50
+		attempts = 100
+		keys     = 5
+	)
+
+	ctx := context.Background()
+	baseline := runtime.NumGoroutine()
+	lm := redis.NewLockManager(redis.LockOptions{TTL: time.Second, Wait: 2 * time.Millisecond})
+
+	holders := make([]atomic.Int32, keys)
+	var violations atomic.Int32
+	var wg sync.WaitGroup
+
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < attempts; i++ {
+				k := (w + i) % keys
+				attemptCtx, cancel := context.WithCancel(ctx)
+				if i%7 == 0 {
+					cancel()
+				}
+
+				lock, err := lm.Acquire(attemptCtx, fmt.Sprintf("tx%d", k))
+				cancel()
+				if err != nil {
+					continue
+				}
+
+				if !holders[k].CompareAndSwap(0, 1) {
+					violations.Add(1)
+				}
+				runtime.Gosched()
+				holders[k].Store(0)
+
+				if err := lm.Unlock(ctx, lock); err != nil {
+					violations.Add(1)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.Zero(t, violations.Load(), "a key must never be held twice")
+
+	metrics := lm.Metrics()
+	assert.Equal(t, uint64(workers*attempts), metrics.Acquired+metrics.TimedOut+metrics.Cancelled)
+	assert.Equal(t, int64(0), metrics.Waiting)
+	assert.Zero(t, lm.Len(), "every acquired lock should be released")
+
+	for k := 0; k < keys; k++ {
+		lock, err := lm.Acquire(ctx, fmt.Sprintf("tx%d", k))
+		require.NoError(t, err, "no key should be poisoned")
+		require.NoError(t, lm.Unlock(ctx, lock))
+	}
+
+	// polled by hand, assert.Eventually runs the condition in a goroutine of its own
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > baseline && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), baseline, "no goroutine should be left behind")
+}
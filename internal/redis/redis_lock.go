@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"payment-service/internal/config"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	lockKeyPrefix      = "lock:"
+	fenceKey           = "lock:fence"
+	lockRetryInterval  = 10 * time.Millisecond
+	lockCommandTimeout = 2 * time.Second
+)
+
+// acquireScript sets the lock key and hands out the next fencing token in one step.
+// A single counter is shared by all keys, it only has to increase, not to be contiguous.
+// The token is returned as a string, Lua numbers are doubles and would round tokens above 2^53.
+var acquireScript = goredis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	redis.call("INCR", KEYS[2])
+	return redis.call("GET", KEYS[2])
+end
+return false
+`)
+
+// seedFenceScript raises the fencing counter to at least ARGV[1], it never lowers it.
+// The values are compared as decimal strings for the same reason.
+var seedFenceScript = goredis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if not current or #current < #ARGV[1] or (#current == #ARGV[1] and current < ARGV[1]) then
+	redis.call("SET", KEYS[1], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// releaseScript deletes the lock only if it still holds the caller's token,
+// so a holder whose lock expired can never release the lock of the next holder.
+var releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is a Locker shared by every replica connected to the same Redis.
+// A lock is a key set with SET NX PX, holding a random token of its owner.
+// Redis expires the key after the TTL, so no reaper is needed.
+type RedisLocker struct {
+	client *goredis.Client
+	opts   LockOptions
+	stats  lockStats
+}
+
+func NewClient(cfg config.RedisConfig) *goredis.Client {
+	return goredis.NewClient(&goredis.Options{
+		Addr:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Password: cfg.Password,
+	})
+}
+
+func NewRedisLocker(client *goredis.Client, opts LockOptions) *RedisLocker {
+	return &RedisLocker{
+		client: client,
+		opts:   opts,
+	}
+}
+
+// Acquire
+// try to set the lock key until it succeeds or the wait budget is over, and take the next fencing token.
+// Redis has no release notification, so waiters retry every lockRetryInterval on a timer of the calling goroutine.
+func (l *RedisLocker) Acquire(ctx context.Context, key string) (*Lock, error) {
+	lock, err := l.tryAcquire(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if lock == nil {
+		start := l.stats.startWait()
+		lock, err = l.wait(ctx, key)
+		l.stats.endWait(start, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l.stats.acquired.Add(1)
+	return lock, nil
+}
+
+func (l *RedisLocker) wait(ctx context.Context, key string) (*Lock, error) {
+	deadline, deadlineErr := waitDeadline(ctx, l.opts.Wait)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return nil, deadlineErr
+		}
+
+		timer.Reset(min(lockRetryInterval, deadline.Sub(now)))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		lock, err := l.tryAcquire(ctx, key)
+		if err != nil || lock != nil {
+			return lock, err
+		}
+	}
+}
+
+// tryAcquire returns a nil lock without error when the key is held by someone else.
+func (l *RedisLocker) tryAcquire(ctx context.Context, key string) (*Lock, error) {
+	token := uuid.NewString()
+	expiresAt := time.Now().Add(l.opts.TTL)
+
+	reply, err := acquireScript.Run(ctx, l.client, []string{lockKeyPrefix + key, fenceKey}, token, l.opts.TTL.Milliseconds()).Text()
+	if errors.Is(err, goredis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fence, err := strconv.ParseUint(reply, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fencing token %q: %w", reply, err)
+	}
+	return &Lock{Key: key, Token: token, Fence: fence, ExpiresAt: expiresAt}, nil
+}
+
+// SeedFence
+// raise the fencing counter to at least floor, so that the next tokens are greater than every token already stored,
+// e.g. by the in-memory LockManager before Redis was enabled, or before the counter was lost with the Redis data.
+func (l *RedisLocker) SeedFence(ctx context.Context, floor uint64) error {
+	return seedFenceScript.Run(ctx, l.client, []string{fenceKey}, strconv.FormatUint(floor, 10)).Err()
+}
+
+func (l *RedisLocker) Metrics() LockMetrics {
+	return l.stats.snapshot()
+}
+
+// Unlock releases the lock if it is still held by the lock's token.
+func (l *RedisLocker) Unlock(ctx context.Context, lock *Lock) error {
+	// release even if the request context was cancelled, otherwise the key stays locked until the TTL
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockCommandTimeout)
+	defer cancel()
+
+	released, err := releaseScript.Run(ctx, l.client, []string{lockKeyPrefix + lock.Key}, lock.Token).Int()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Ping checks the connection, used on startup to fail fast on a wrong configuration.
+func (l *RedisLocker) Ping(ctx context.Context) error {
+	if err := l.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-service/internal/redis"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRedisLocker(t *testing.T, ttl time.Duration) (*redis.RedisLocker, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return redis.NewRedisLocker(client, redis.LockOptions{TTL: ttl, Wait: redis.DefaultLockWait}), server
+}
+
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	locker, server := newRedisLocker(t, redis.DefaultLockTTL)
+
+	lock, err := locker.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	t.Run("Second holder should not acquire a held lock", func(t *testing.T) {
+		_, err := locker.Acquire(ctx, "tx123")
+		assert.ErrorIs(t, err, redis.ErrLockNotAcquired)
+	})
+
+	t.Run("Different key should be acquired independently", func(t *testing.T) {
+		other, err := locker.Acquire(ctx, "tx456")
+		assert.NoError(t, err)
+		assert.NoError(t, locker.Unlock(ctx, other))
+	})
+
+	t.Run("Release with a wrong token should not release the lock", func(t *testing.T) {
+		err := locker.Unlock(ctx, &redis.Lock{Key: "tx123", Token: "someone-else"})
+		assert.ErrorIs(t, err, redis.ErrLockNotHeld)
+		assert.True(t, server.Exists("lock:tx123"))
+	})
+
+	t.Run("Owner releases and the lock can be acquired again", func(t *testing.T) {
+		assert.NoError(t, locker.Unlock(ctx, lock))
+		again, err := locker.Acquire(ctx, "tx123")
+		assert.NoError(t, err)
+		assert.NoError(t, locker.Unlock(ctx, again))
+	})
+}
+
+func TestRedisLockerExpiry(t *testing.T) {
+	ctx := context.Background()
+	locker, server := newRedisLocker(t, time.Second)
+
+	stale, err := locker.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	server.FastForward(2 * time.Second)
+
+	fresh, err := locker.Acquire(ctx, "tx123")
+	require.NoError(t, err, "expired lock should be acquired by the next holder")
+	assert.Greater(t, fresh.Fence, stale.Fence, "fencing token should increase with every acquisition")
+
+	assert.ErrorIs(t, locker.Unlock(ctx, stale), redis.ErrLockNotHeld, "expired holder must not release the new lock")
+	assert.NoError(t, locker.Unlock(ctx, fresh))
+}
+
+func TestRedisLockerSeedFence(t *testing.T) {
+	ctx := context.Background()
+	locker, _ := newRedisLocker(t, redis.DefaultLockTTL)
+
+	// tokens of the in-memory locker are seeded from the clock, far above 2^53
+	stored := uint64(1_700_000_000_000_000_123)
+	require.NoError(t, locker.SeedFence(ctx, stored))
+
+	lock, err := locker.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+	assert.Equal(t, stored+1, lock.Fence, "the next token should be exactly above the seed")
+	require.NoError(t, locker.Unlock(ctx, lock))
+
+	// seeding lower never moves the counter back
+	require.NoError(t, locker.SeedFence(ctx, 10))
+	lock, err = locker.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+	assert.Equal(t, stored+2, lock.Fence)
+	require.NoError(t, locker.Unlock(ctx, lock))
+}
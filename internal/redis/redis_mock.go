@@ -1,45 +1,174 @@
 package redis
 
 import (
+	"context"
 	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
-// TODO: implement Redis for future
+type lockEntry struct {
+	token     string
+	expiresAt time.Time
+	// closed when the lock is released, evicted or taken over, wakes up the waiters
+	released chan struct{}
+}
+
 // Simulate redis
+// LockManager is the in-memory Locker, it only serializes callers inside one process.
+// Locks expire after the TTL like a Redis key would, expired entries are evicted by the reaper.
 type LockManager struct {
-	// key: idempotencyKey(transactionID), value: *sync.Mutex
-	// TODO: apply worker to clear expired locks everyday
-	locks sync.Map
+	mu sync.Mutex
+	// key: idempotencyKey(transactionID)
+	locks map[string]*lockEntry
+	fence uint64
+	opts  LockOptions
+	stats lockStats
+}
+
+func NewLockManager(opts LockOptions) *LockManager {
+	return &LockManager{
+		locks: make(map[string]*lockEntry),
+		// fencing tokens are checked against values stored in the database, seeding from the clock
+		// keeps them increasing across restarts of the process
+		fence: uint64(time.Now().UnixNano()),
+		opts:  opts,
+	}
+}
+
+// Acquire
+// take the lock, or wait for it to be released or to expire, within the wait budget.
+// Waiters sleep on the entry's released channel and a timer of the calling goroutine,
+// no goroutine is started, so nothing is left behind when the wait is given up.
+func (lm *LockManager) Acquire(ctx context.Context, key string) (*Lock, error) {
+	lock, released, expiresAt := lm.tryAcquire(key)
+	if lock == nil {
+		start := lm.stats.startWait()
+		var err error
+		lock, err = lm.wait(ctx, key, released, expiresAt)
+		lm.stats.endWait(start, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lm.stats.acquired.Add(1)
+	return lock, nil
+}
+
+func (lm *LockManager) wait(ctx context.Context, key string, released <-chan struct{}, expiresAt time.Time) (*Lock, error) {
+	deadline, deadlineErr := waitDeadline(ctx, lm.opts.Wait)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return nil, deadlineErr
+		}
+
+		// the holder's lease may run out before the budget does
+		wakeAt := deadline
+		if expiresAt.Before(wakeAt) {
+			wakeAt = expiresAt
+		}
+		timer.Reset(wakeAt.Sub(now))
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-released:
+		case <-timer.C:
+		}
+
+		var lock *Lock
+		if lock, released, expiresAt = lm.tryAcquire(key); lock != nil {
+			return lock, nil
+		}
+	}
 }
 
-func NewLockManager() *LockManager {
-	return &LockManager{}
+// tryAcquire takes the lock if it is free or expired,
+// otherwise it returns what the caller needs to wait for the current holder.
+func (lm *LockManager) tryAcquire(key string) (*Lock, <-chan struct{}, time.Time) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := lm.locks[key]; ok {
+		if now.Before(entry.expiresAt) {
+			return nil, entry.released, entry.expiresAt
+		}
+		close(entry.released)
+	}
+
+	lm.fence++
+	entry := &lockEntry{
+		token:     uuid.NewString(),
+		expiresAt: now.Add(lm.opts.TTL),
+		released:  make(chan struct{}),
+	}
+	lm.locks[key] = entry
+
+	return &Lock{Key: key, Token: entry.token, Fence: lm.fence, ExpiresAt: entry.expiresAt}, nil, time.Time{}
 }
 
-func (lm *LockManager) TryLock(key string) (*sync.Mutex, bool) {
-	mu := &sync.Mutex{}
-	actual, _ := lm.locks.LoadOrStore(key, mu)
-	lock := actual.(*sync.Mutex)
+// Unlock releases the lock if it is still held by the lock's token.
+func (lm *LockManager) Unlock(ctx context.Context, lock *Lock) error {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	entry, ok := lm.locks[lock.Key]
+	if !ok || entry.token != lock.Token {
+		return ErrLockNotHeld
+	}
+
+	delete(lm.locks, lock.Key)
+	close(entry.released)
+	if time.Now().After(entry.expiresAt) {
+		return ErrLockNotHeld
+	}
+	return nil
+}
 
-	locked := make(chan struct{}, 1)
+func (lm *LockManager) Metrics() LockMetrics {
+	return lm.stats.snapshot()
+}
 
+// StartReaper evicts expired locks every interval until ctx is done.
+func (lm *LockManager) StartReaper(ctx context.Context, interval time.Duration) {
 	go func() {
-		lock.Lock()
-		locked <- struct{}{}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				lm.evictExpired()
+			}
+		}
 	}()
+}
 
-	// try to get lock，100ms timeout
-	select {
-	case <-locked:
-		return lock, true
-	case <-time.After(100 * time.Millisecond):
-		return nil, false
+func (lm *LockManager) evictExpired() {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	now := time.Now()
+	for key, entry := range lm.locks {
+		if now.After(entry.expiresAt) {
+			delete(lm.locks, key)
+			close(entry.released)
+		}
 	}
 }
 
-func (lm *LockManager) Unlock(key string) {
-	if v, ok := lm.locks.Load(key); ok {
-		v.(*sync.Mutex).Unlock()
-	}
+// Len returns the number of entries currently kept, held or expired but not evicted yet.
+func (lm *LockManager) Len() int {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	return len(lm.locks)
 }
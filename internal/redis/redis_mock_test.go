@@ -0,0 +1,73 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"payment-service/internal/redis"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockManager(t *testing.T) {
+	ctx := context.Background()
+	lm := redis.NewLockManager(redis.DefaultLockOptions())
+
+	lock, err := lm.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	t.Run("Second holder should not acquire a held lock", func(t *testing.T) {
+		_, err := lm.Acquire(ctx, "tx123")
+		assert.ErrorIs(t, err, redis.ErrLockNotAcquired)
+	})
+
+	t.Run("Release with a wrong token should not release the lock", func(t *testing.T) {
+		err := lm.Unlock(ctx, &redis.Lock{Key: "tx123", Token: "someone-else"})
+		assert.ErrorIs(t, err, redis.ErrLockNotHeld)
+		_, err = lm.Acquire(ctx, "tx123")
+		assert.ErrorIs(t, err, redis.ErrLockNotAcquired)
+	})
+
+	t.Run("Owner releases and the next holder gets a higher fencing token", func(t *testing.T) {
+		assert.NoError(t, lm.Unlock(ctx, lock))
+		next, err := lm.Acquire(ctx, "tx123")
+		require.NoError(t, err)
+		assert.Greater(t, next.Fence, lock.Fence)
+		assert.NoError(t, lm.Unlock(ctx, next))
+	})
+}
+
+func TestLockManagerExpiry(t *testing.T) {
+	ctx := context.Background()
+	lm := redis.NewLockManager(redis.LockOptions{TTL: 50 * time.Millisecond, Wait: redis.DefaultLockWait})
+
+	stale, err := lm.Acquire(ctx, "tx123")
+	require.NoError(t, err)
+
+	time.Sleep(60 * time.Millisecond)
+
+	fresh, err := lm.Acquire(ctx, "tx123")
+	require.NoError(t, err, "expired lock should be acquired by the next holder")
+	assert.Greater(t, fresh.Fence, stale.Fence)
+
+	assert.ErrorIs(t, lm.Unlock(ctx, stale), redis.ErrLockNotHeld, "expired holder must not release the new lock")
+	assert.NoError(t, lm.Unlock(ctx, fresh))
+}
+
+func TestLockManagerReaper(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	lm := redis.NewLockManager(redis.LockOptions{TTL: 20 * time.Millisecond, Wait: redis.DefaultLockWait})
+	lm.StartReaper(ctx, 10*time.Millisecond)
+
+	for _, key := range []string{"tx1", "tx2", "tx3"} {
+		_, err := lm.Acquire(ctx, key)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, lm.Len())
+
+	assert.Eventually(t, func() bool { return lm.Len() == 0 }, time.Second, 10*time.Millisecond, "expired locks should be evicted")
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DepositRepository interface {
+	Create(tx *gorm.DB, deposit *models.Deposit) error
+	GetByDepositID(depositID string) (*models.Deposit, error)
+	GetForUpdate(tx *gorm.DB, id uint) (*models.Deposit, error)
+	ListByUserID(userID string) ([]*models.Deposit, error)
+	UpdateStatus(tx *gorm.DB, deposit *models.Deposit, from models.DepositStatus) error
+}
+
+type depositRepository struct {
+	db *gorm.DB
+}
+
+func NewDepositRepository(db *gorm.DB) DepositRepository {
+	return &depositRepository{db: db}
+}
+
+func (r *depositRepository) Create(tx *gorm.DB, deposit *models.Deposit) error {
+	return tx.Create(deposit).Error
+}
+
+func (r *depositRepository) GetByDepositID(depositID string) (*models.Deposit, error) {
+	var deposit models.Deposit
+	if err := r.db.Where("deposit_id = ?", depositID).First(&deposit).Error; err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+// GetForUpdate
+// lock the deposit row for the duration of the transaction, used to re-check its status before crediting the wallet.
+func (r *depositRepository) GetForUpdate(tx *gorm.DB, id uint) (*models.Deposit, error) {
+	var deposit models.Deposit
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&deposit, id).Error; err != nil {
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+func (r *depositRepository) ListByUserID(userID string) ([]*models.Deposit, error) {
+	var deposits []*models.Deposit
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	return deposits, nil
+}
+
+// UpdateStatus
+// write the status of the deposit, guarded by the status it was read in.
+// It fails with models.ErrStatusChanged if the row was changed since.
+func (r *depositRepository) UpdateStatus(tx *gorm.DB, deposit *models.Deposit, from models.DepositStatus) error {
+	result := tx.Model(deposit).Where("status = ?", from).Update("status", deposit.Status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrStatusChanged
+	}
+	return nil
+}
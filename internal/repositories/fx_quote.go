@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type FxQuoteRepository interface {
+	Create(quote *models.FxQuote) error
+	GetByQuoteID(quoteID string) (*models.FxQuote, error)
+}
+
+type fxQuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewFxQuoteRepository(db *gorm.DB) FxQuoteRepository {
+	return &fxQuoteRepository{db: db}
+}
+
+func (r *fxQuoteRepository) Create(quote *models.FxQuote) error {
+	return r.db.Create(quote).Error
+}
+
+func (r *fxQuoteRepository) GetByQuoteID(quoteID string) (*models.FxQuote, error) {
+	var quote models.FxQuote
+	if err := r.db.Where("quote_id = ?", quoteID).First(&quote).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"errors"
+
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIdempotencyRecordReleased is returned when storing the response of a request whose record was released in the
+// meantime, e.g. as stale while the request was still running.
+var ErrIdempotencyRecordReleased = errors.New("idempotency record was released before its response was stored")
+
+type IdempotencyRepository interface {
+	CreateIfAbsent(record *models.IdempotencyRecord) (bool, error)
+	GetByKey(key string) (*models.IdempotencyRecord, error)
+	SaveResponse(record *models.IdempotencyRecord) error
+	DeleteByKey(key string) error
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// CreateIfAbsent
+// insert the record unless another request already claimed the same key.
+// The unique index on key makes the check-and-insert atomic, so only one caller gets true.
+func (r *idempotencyRepository) CreateIfAbsent(record *models.IdempotencyRecord) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *idempotencyRepository) GetByKey(key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	if err := r.db.Where("idempotency_key = ?", key).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SaveResponse
+// store the response of the request that claimed the record, it fails with ErrIdempotencyRecordReleased if the
+// record no longer exists.
+func (r *idempotencyRepository) SaveResponse(record *models.IdempotencyRecord) error {
+	result := r.db.Model(record).Updates(map[string]interface{}{
+		"status_code":   record.StatusCode,
+		"content_type":  record.ContentType,
+		"response_body": record.ResponseBody,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyRecordReleased
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteByKey(key string) error {
+	return r.db.Where("idempotency_key = ?", key).Delete(&models.IdempotencyRecord{}).Error
+}
@@ -0,0 +1,189 @@
+package repositories
+
+import (
+	"time"
+
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepository interface {
+	Post(tx *gorm.DB, entries []*models.LedgerEntry) error
+	ListByAccount(account string) ([]*models.LedgerEntry, error)
+	ListByPayment(paymentID uint) ([]*models.LedgerEntry, error)
+	WalletBalance(tx *gorm.DB, walletID uint) (decimal.Decimal, error)
+	HasWalletEntries(tx *gorm.DB, walletID uint) (bool, error)
+	ListUnpostedWallets() ([]*models.Wallet, error)
+	ListUnpostedPayments(tx *gorm.DB, wallet *models.Wallet) ([]*models.Payment, error)
+	FindMismatchedWallets() ([]*models.WalletReconciliation, error)
+	SaveReconciliations(records []*models.WalletReconciliation) ([]*models.WalletReconciliation, error)
+	ListReconciliations(limit int) ([]*models.WalletReconciliation, error)
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// Post
+// write a balanced posting in tx, an unbalanced posting is rejected with models.ErrUnbalancedPosting.
+func (r *ledgerRepository) Post(tx *gorm.DB, entries []*models.LedgerEntry) error {
+	if err := models.CheckBalanced(entries); err != nil {
+		return err
+	}
+	return tx.Create(entries).Error
+}
+
+func (r *ledgerRepository) ListByAccount(account string) ([]*models.LedgerEntry, error) {
+	var entries []*models.LedgerEntry
+	if err := r.db.Where("account = ?", account).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *ledgerRepository) ListByPayment(paymentID uint) ([]*models.LedgerEntry, error) {
+	var entries []*models.LedgerEntry
+	if err := r.db.Where("payment_id = ?", paymentID).Order("id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// WalletBalance
+// the balance of a wallet as derived from its ledger entries, credits minus debits.
+func (r *ledgerRepository) WalletBalance(tx *gorm.DB, walletID uint) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	if err := tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", models.Credit).
+		Where("wallet_id = ?", walletID).
+		Scan(&balance).Error; err != nil {
+		return decimal.Zero, err
+	}
+	return balance, nil
+}
+
+func (r *ledgerRepository) HasWalletEntries(tx *gorm.DB, walletID uint) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.LedgerEntry{}).Where("wallet_id = ?", walletID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ListUnpostedWallets
+// the wallets without any ledger entry, these were created before the ledger existed.
+func (r *ledgerRepository) ListUnpostedWallets() ([]*models.Wallet, error) {
+	var wallets []*models.Wallet
+	if err := r.db.
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.wallet_id = wallets.id)").
+		Order("id").
+		Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// ListUnpostedPayments
+// the settled payments charged to the wallet that have no ledger entries, oldest first.
+// Payments created before FX existed have no wallet_currency and were charged in their own currency.
+func (r *ledgerRepository) ListUnpostedPayments(tx *gorm.DB, wallet *models.Wallet) ([]*models.Payment, error) {
+	var payments []*models.Payment
+	if err := tx.
+		Where("user_id = ? AND status IN ?", wallet.UserID, models.SettledStatuses).
+		Where("COALESCE(NULLIF(wallet_currency, ''), currency) = ?", wallet.Currency).
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entries WHERE ledger_entries.payment_id = payments.id)").
+		Order("created_at, id").
+		Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// FindMismatchedWallets
+// compare the stored balance of every wallet with the sum of its ledger entries in one query,
+// and return the wallets where they differ.
+func (r *ledgerRepository) FindMismatchedWallets() ([]*models.WalletReconciliation, error) {
+	var rows []struct {
+		WalletID      uint
+		UserID        string
+		StoredBalance decimal.Decimal
+		LedgerBalance decimal.Decimal
+	}
+	if err := r.db.Table("wallets").
+		Select(`wallets.id AS wallet_id, wallets.user_id, wallets.balance AS stored_balance,
+			COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE -ledger_entries.amount END), 0) AS ledger_balance`,
+			models.Credit).
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.wallet_id = wallets.id").
+		Group("wallets.id, wallets.user_id, wallets.balance").
+		Having(`wallets.balance <> COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE -ledger_entries.amount END), 0)`,
+			models.Credit).
+		Order("wallets.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	records := make([]*models.WalletReconciliation, 0, len(rows))
+	for _, row := range rows {
+		records = append(records, &models.WalletReconciliation{
+			WalletID:      row.WalletID,
+			UserID:        row.UserID,
+			StoredBalance: row.StoredBalance,
+			LedgerBalance: row.LedgerBalance,
+			Difference:    row.StoredBalance.Sub(row.LedgerBalance),
+			CheckedAt:     now,
+		})
+	}
+	return records, nil
+}
+
+// SaveReconciliations
+// record the mismatches that are new, or that changed since the wallet was last recorded, and return them.
+// The wallet row is locked while its last record is compared, so that replicas reconciling at the same time
+// record a mismatch once.
+func (r *ledgerRepository) SaveReconciliations(records []*models.WalletReconciliation) ([]*models.WalletReconciliation, error) {
+	saved := make([]*models.WalletReconciliation, 0, len(records))
+	for _, record := range records {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var wallet models.Wallet
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&wallet, record.WalletID).Error; err != nil {
+				return err
+			}
+
+			var last []*models.WalletReconciliation
+			if err := tx.Where("wallet_id = ?", record.WalletID).Order("id DESC").Limit(1).Find(&last).Error; err != nil {
+				return err
+			}
+			if len(last) == 1 &&
+				last[0].StoredBalance.Equal(record.StoredBalance) &&
+				last[0].LedgerBalance.Equal(record.LedgerBalance) {
+				return nil
+			}
+
+			if err := tx.Create(record).Error; err != nil {
+				return err
+			}
+			saved = append(saved, record)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return saved, nil
+}
+
+func (r *ledgerRepository) ListReconciliations(limit int) ([]*models.WalletReconciliation, error) {
+	var records []*models.WalletReconciliation
+	if err := r.db.Order("id DESC").Limit(limit).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"errors"
+
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrStaleFenceToken = errors.New("stale fencing token, the lock was taken over by another holder")
+
+type LockFenceRepository interface {
+	Advance(tx *gorm.DB, key string, fenceToken uint64) error
+	MaxFenceToken() (uint64, error)
+}
+
+type lockFenceRepository struct {
+	db *gorm.DB
+}
+
+func NewLockFenceRepository(db *gorm.DB) LockFenceRepository {
+	return &lockFenceRepository{db: db}
+}
+
+// Advance
+// record the fencing token of a write under the lock key, inside the transaction of the write.
+// It fails with ErrStaleFenceToken when a newer holder already wrote under the same key.
+// The row stays locked until the transaction ends, so two holders cannot pass the check concurrently.
+func (r *lockFenceRepository) Advance(tx *gorm.DB, key string, fenceToken uint64) error {
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "lock_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fence_token", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "lock_fences.fence_token <= excluded.fence_token"},
+		}},
+	}).Create(&models.LockFence{LockKey: key, FenceToken: fenceToken})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrStaleFenceToken
+	}
+	return nil
+}
+
+// MaxFenceToken
+// the highest fencing token stored under any key, 0 if there is none.
+func (r *lockFenceRepository) MaxFenceToken() (uint64, error) {
+	var fence uint64
+	if err := r.db.Model(&models.LockFence{}).Select("COALESCE(MAX(fence_token), 0)").Scan(&fence).Error; err != nil {
+		return 0, err
+	}
+	return fence, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"time"
+
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxEventRepository interface {
+	Create(tx *gorm.DB, event *models.OutboxEvent) error
+	GetByEventID(eventID string) (*models.OutboxEvent, error)
+	ListByAggregate(aggregateType string, aggregateID uint) ([]*models.OutboxEvent, error)
+	ClaimBatch(limit int, visibilityTimeout time.Duration) ([]*models.OutboxEvent, error)
+	MarkPublished(event *models.OutboxEvent) error
+	Reschedule(event *models.OutboxEvent, nextAttemptAt time.Time, lastError string) error
+}
+
+type outboxEventRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxEventRepository(db *gorm.DB) OutboxEventRepository {
+	return &outboxEventRepository{db: db}
+}
+
+func (r *outboxEventRepository) Create(tx *gorm.DB, event *models.OutboxEvent) error {
+	return tx.Create(event).Error
+}
+
+func (r *outboxEventRepository) GetByEventID(eventID string) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	if err := r.db.Where("event_id = ?", eventID).First(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *outboxEventRepository) ListByAggregate(aggregateType string, aggregateID uint) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	if err := r.db.Where("aggregate_type = ? AND aggregate_id = ?", aggregateType, aggregateID).
+		Order("id").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ClaimBatch
+// claim up to limit due events in the order they were written.
+// SKIP LOCKED lets several relays share the table, the claim pushes next_attempt_at out by the visibility
+// timeout so the events of a relay that died are claimed again.
+func (r *outboxEventRepository) ClaimBatch(limit int, visibilityTimeout time.Duration) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	now := time.Now()
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(events))
+		for _, event := range events {
+			event.Attempts++
+			event.NextAttemptAt = now.Add(visibilityTimeout)
+			ids = append(ids, event.ID)
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(visibilityTimeout),
+		}).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (r *outboxEventRepository) MarkPublished(event *models.OutboxEvent) error {
+	now := time.Now()
+	event.Status = models.OutboxPublished
+	event.PublishedAt = &now
+	return r.db.Model(event).Updates(map[string]interface{}{
+		"status":       event.Status,
+		"published_at": event.PublishedAt,
+	}).Error
+}
+
+func (r *outboxEventRepository) Reschedule(event *models.OutboxEvent, nextAttemptAt time.Time, lastError string) error {
+	event.NextAttemptAt = nextAttemptAt
+	event.LastError = lastError
+	return r.db.Model(event).Updates(map[string]interface{}{
+		"next_attempt_at": event.NextAttemptAt,
+		"last_error":      event.LastError,
+	}).Error
+}
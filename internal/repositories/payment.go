@@ -1,16 +1,27 @@
 package repositories
 
 import (
+	"context"
+	"time"
+
 	"payment-service/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PaymentRepository interface {
-	Create(payment *models.Payment) error
-	GetAll() ([]*models.Payment, error)
+	Create(tx *gorm.DB, payment *models.Payment) error
+	List(filter *models.PaymentFilter, page *models.PageQuery, after *models.Cursor) ([]*models.Payment, error)
+	Stream(ctx context.Context, filter *models.PaymentFilter, fn func(payment *models.Payment) error) error
+	ListByUser(userID string, status models.PaymentStatus, page *models.PageQuery, after *models.Cursor) ([]*models.UserPayment, error)
+	GetByID(id uint) (*models.Payment, error)
+	GetForUpdate(tx *gorm.DB, id uint) (*models.Payment, error)
 	GetByTransactionID(transactionID string) (*models.Payment, error)
-	Update(tx *gorm.DB, payment *models.Payment) error
+	ListPendingCreatedBefore(before time.Time, limit int) ([]*models.Payment, error)
+	Transition(tx *gorm.DB, payment *models.Payment, to models.PaymentStatus, reason string) error
+	MarkCapturing(tx *gorm.DB, payment *models.Payment) error
+	ListHistory(paymentID uint) ([]*models.PaymentStatusHistory, error)
 	Delete(id uint) error
 }
 
@@ -24,18 +35,128 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 	}
 }
 
-func (r *paymentRepository) Create(payment *models.Payment) error {
-	return r.db.Create(payment).Error
+// Create
+// insert the payment and the first entry of its status history.
+func (r *paymentRepository) Create(tx *gorm.DB, payment *models.Payment) error {
+	if err := tx.Create(payment).Error; err != nil {
+		return err
+	}
+	return tx.Create(&models.PaymentStatusHistory{
+		PaymentID: payment.ID,
+		ToStatus:  payment.Status,
+		Reason:    "created",
+	}).Error
 }
 
-func (r *paymentRepository) GetAll() ([]*models.Payment, error) {
+// List
+// return a page of the payments matching the filter, following the cursor after, see keyset.
+func (r *paymentRepository) List(filter *models.PaymentFilter, page *models.PageQuery, after *models.Cursor) ([]*models.Payment, error) {
 	var payments []*models.Payment
-	if err := r.db.Find(&payments).Error; err != nil {
+	if err := keyset(filterPayments(r.db, filter), page, after).Find(&payments).Error; err != nil {
 		return nil, err
 	}
 	return payments, nil
 }
 
+// Stream
+// call fn for every payment matching the filter, oldest first. The rows are read from the result set one at a time,
+// so memory stays flat whatever the number of payments; an error returned by fn stops the stream and is returned.
+func (r *paymentRepository) Stream(ctx context.Context, filter *models.PaymentFilter, fn func(payment *models.Payment) error) error {
+	rows, err := filterPayments(r.db.WithContext(ctx).Model(&models.Payment{}), filter).Order("created_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var payment models.Payment
+		if err := r.db.ScanRows(rows, &payment); err != nil {
+			return err
+		}
+		if err := fn(&payment); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListByUser
+// return a page of the user's payments like List, optionally in a single status, each with the running total of
+// the money settled in its currency: the amount less the refunds of the completed payments, see
+// models.SettledStatuses. The total is computed over all the listed payments of the user before the cursor is
+// applied, so it does not depend on the page.
+func (r *paymentRepository) ListByUser(userID string, status models.PaymentStatus, page *models.PageQuery, after *models.Cursor) ([]*models.UserPayment, error) {
+	listed := r.db.Model(&models.Payment{}).
+		Select("payments.*, SUM(CASE WHEN status IN ? THEN amount - refunded_amount ELSE 0 END) "+
+			"OVER (PARTITION BY currency ORDER BY created_at, id) AS running_total", models.SettledStatuses).
+		Where("user_id = ?", userID)
+	if status != "" {
+		listed = listed.Where("status = ?", status)
+	}
+
+	var payments []*models.UserPayment
+	if err := keyset(r.db.Table("(?) AS payments", listed), page, after).Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// keyset orders query by (created_at, id) in the order of the page, continues after the cursor and limits it to
+// page.Size()+1 rows, the extra row tells the caller whether there is a next page. The row comparison is served by
+// the composite indexes of the payments table.
+func keyset(query *gorm.DB, page *models.PageQuery, after *models.Cursor) *gorm.DB {
+	order, op := "created_at, id", ">"
+	if page.Descending() {
+		order, op = "created_at DESC, id DESC", "<"
+	}
+	if after != nil {
+		query = query.Where("(created_at, id) "+op+" (?, ?)", after.CreatedAt, after.ID)
+	}
+	return query.Order(order).Limit(page.Size() + 1)
+}
+
+// filterPayments adds the conditions of the filter to query.
+func filterPayments(query *gorm.DB, filter *models.PaymentFilter) *gorm.DB {
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	return query
+}
+
+func (r *paymentRepository) GetByID(id uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.First(&payment, id).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// GetForUpdate
+// lock the payment row for the duration of the transaction,
+// used to re-check the status of a payment before changing it.
+func (r *paymentRepository) GetForUpdate(tx *gorm.DB, id uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, id).Error; err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
 func (r *paymentRepository) GetByTransactionID(transactionID string) (*models.Payment, error) {
 	var payment models.Payment
 	if err := r.db.Where("transaction_id = ?", transactionID).First(&payment).Error; err != nil {
@@ -44,8 +165,74 @@ func (r *paymentRepository) GetByTransactionID(transactionID string) (*models.Pa
 	return &payment, nil
 }
 
-func (r *paymentRepository) Update(tx *gorm.DB, payment *models.Payment) error {
-	return tx.Save(payment).Error
+// ListPendingCreatedBefore
+// the payments pending since before, oldest first, leaving out those whose job is still queued or running:
+// they are being processed, and would otherwise fill every batch ahead of the payments that are really stuck.
+func (r *paymentRepository) ListPendingCreatedBefore(before time.Time, limit int) ([]*models.Payment, error) {
+	var payments []*models.Payment
+	if err := r.db.Where("status = ? AND created_at < ?", models.StatusPending, before).
+		Where("NOT EXISTS (SELECT 1 FROM payment_jobs WHERE payment_jobs.payment_id = payments.id AND payment_jobs.status IN ?)",
+			[]models.PaymentJobStatus{models.JobQueued, models.JobRunning}).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&payments).Error; err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+// Transition
+// move the payment to status to, with its failure reason and refunded amount, and record the change in its history.
+// The transition must be allowed by the state machine, and the update is guarded by the status the payment was read in
+// (compare-and-set), it fails with models.ErrStatusChanged if the row was changed since.
+func (r *paymentRepository) Transition(tx *gorm.DB, payment *models.Payment, to models.PaymentStatus, reason string) error {
+	from := payment.Status
+	if err := payment.TransitionTo(to); err != nil {
+		return err
+	}
+
+	result := tx.Model(payment).Where("status = ?", from).Updates(map[string]interface{}{
+		"status":          payment.Status,
+		"failure_reason":  payment.FailureReason,
+		"refunded_amount": payment.RefundedAmount,
+	})
+	if result.Error != nil {
+		payment.Status = from
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		payment.Status = from
+		return models.ErrStatusChanged
+	}
+
+	return tx.Create(&models.PaymentStatusHistory{
+		PaymentID:  payment.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+	}).Error
+}
+
+// MarkCapturing
+// flag the pending payment as being captured at the processor, guarded like Transition by its pending status,
+// it fails with models.ErrStatusChanged if the payment is no longer pending.
+func (r *paymentRepository) MarkCapturing(tx *gorm.DB, payment *models.Payment) error {
+	result := tx.Model(payment).Where("status = ?", models.StatusPending).Update("capturing", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return models.ErrStatusChanged
+	}
+	return nil
+}
+
+func (r *paymentRepository) ListHistory(paymentID uint) ([]*models.PaymentStatusHistory, error) {
+	var history []*models.PaymentStatusHistory
+	if err := r.db.Where("payment_id = ?", paymentID).Order("id").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
 }
 
 func (r *paymentRepository) Delete(id uint) error {
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJobNotOwned is returned when updating a job that was claimed again by another worker since it was claimed,
+// e.g. because it ran past the visibility timeout. The update is not applied.
+var ErrJobNotOwned = errors.New("payment job was claimed again by another worker")
+
+type PaymentJobRepository interface {
+	Enqueue(tx *gorm.DB, job *models.PaymentJob) error
+	GetByPaymentID(paymentID uint) (*models.PaymentJob, error)
+	Resume(paymentID uint) error
+	ClaimNext(visibilityTimeout time.Duration) (*models.PaymentJob, error)
+	MarkSucceeded(job *models.PaymentJob) error
+	Reschedule(job *models.PaymentJob, runAt time.Time, lastError string) error
+	MarkDead(job *models.PaymentJob, lastError string) error
+}
+
+type paymentJobRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentJobRepository(db *gorm.DB) PaymentJobRepository {
+	return &paymentJobRepository{db: db}
+}
+
+func (r *paymentJobRepository) Enqueue(tx *gorm.DB, job *models.PaymentJob) error {
+	return tx.Create(job).Error
+}
+
+func (r *paymentJobRepository) GetByPaymentID(paymentID uint) (*models.PaymentJob, error) {
+	var job models.PaymentJob
+	if err := r.db.Where("payment_id = ?", paymentID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Resume
+// queue the job of a payment to run now with fresh attempts, creating it if the payment has none
+// (e.g. payments created before jobs existed, or a job that was lost).
+func (r *paymentJobRepository) Resume(paymentID uint) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "payment_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"status":     models.JobQueued,
+			"attempts":   0,
+			"run_at":     gorm.Expr("excluded.run_at"),
+			"locked_at":  nil,
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(&models.PaymentJob{
+		PaymentID: paymentID,
+		Status:    models.JobQueued,
+		RunAt:     time.Now(),
+	}).Error
+}
+
+// ClaimNext
+// claim the next due job and mark it running, returns gorm.ErrRecordNotFound when there is none.
+// SKIP LOCKED lets concurrent workers pass over rows another worker is claiming instead of waiting.
+// A running job whose worker did not report back within the visibility timeout (e.g. the process died)
+// is claimed again.
+func (r *paymentJobRepository) ClaimNext(visibilityTimeout time.Duration) (*models.PaymentJob, error) {
+	var job models.PaymentJob
+	// locked_at identifies the claim, it is kept at the precision Postgres stores so that it compares equal
+	now := time.Now().Truncate(time.Microsecond)
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_at < ?)",
+				models.JobQueued, now, models.JobRunning, now.Add(-visibilityTimeout)).
+			Order("run_at").
+			First(&job).Error; err != nil {
+			return err
+		}
+
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_at": job.LockedAt,
+		}).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// MarkSucceeded
+// mark the claimed job succeeded, it fails with ErrJobNotOwned if the job was claimed again since.
+func (r *paymentJobRepository) MarkSucceeded(job *models.PaymentJob) error {
+	return r.updateClaimed(job, map[string]interface{}{
+		"status":    models.JobSucceeded,
+		"locked_at": nil,
+	})
+}
+
+// Reschedule
+// queue the claimed job to run again at runAt, it fails with ErrJobNotOwned if the job was claimed again since.
+func (r *paymentJobRepository) Reschedule(job *models.PaymentJob, runAt time.Time, lastError string) error {
+	return r.updateClaimed(job, map[string]interface{}{
+		"status":     models.JobQueued,
+		"run_at":     runAt,
+		"locked_at":  nil,
+		"last_error": lastError,
+	})
+}
+
+// MarkDead
+// give up on the claimed job, it fails with ErrJobNotOwned if the job was claimed again since.
+func (r *paymentJobRepository) MarkDead(job *models.PaymentJob, lastError string) error {
+	return r.updateClaimed(job, map[string]interface{}{
+		"status":     models.JobDead,
+		"locked_at":  nil,
+		"last_error": lastError,
+	})
+}
+
+// updateClaimed applies the update only while the job is still running under the claim of the caller,
+// a worker that outlived the visibility timeout must not overwrite the work of the worker that claimed the job again.
+func (r *paymentJobRepository) updateClaimed(job *models.PaymentJob, updates map[string]interface{}) error {
+	result := r.db.Model(&models.PaymentJob{}).
+		Where("id = ? AND status = ? AND locked_at = ?", job.ID, models.JobRunning, job.LockedAt).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrJobNotOwned
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type PaymentRecoveryAuditRepository interface {
+	Create(audit *models.PaymentRecoveryAudit) error
+	GetByPaymentID(paymentID uint) ([]*models.PaymentRecoveryAudit, error)
+}
+
+type paymentRecoveryAuditRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRecoveryAuditRepository(db *gorm.DB) PaymentRecoveryAuditRepository {
+	return &paymentRecoveryAuditRepository{db: db}
+}
+
+func (r *paymentRecoveryAuditRepository) Create(audit *models.PaymentRecoveryAudit) error {
+	return r.db.Create(audit).Error
+}
+
+func (r *paymentRecoveryAuditRepository) GetByPaymentID(paymentID uint) ([]*models.PaymentRecoveryAudit, error) {
+	var audits []*models.PaymentRecoveryAudit
+	if err := r.db.Where("payment_id = ?", paymentID).Order("id").Find(&audits).Error; err != nil {
+		return nil, err
+	}
+	return audits, nil
+}
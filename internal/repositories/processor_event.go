@@ -0,0 +1,39 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ProcessorEventRepository interface {
+	CreateIfAbsent(tx *gorm.DB, event *models.ProcessorEvent) (bool, error)
+	GetByEventID(eventID string) (*models.ProcessorEvent, error)
+}
+
+type processorEventRepository struct {
+	db *gorm.DB
+}
+
+func NewProcessorEventRepository(db *gorm.DB) ProcessorEventRepository {
+	return &processorEventRepository{db: db}
+}
+
+// CreateIfAbsent
+// record the event unless an event with the same event ID was already recorded, only one caller gets true.
+func (r *processorEventRepository) CreateIfAbsent(tx *gorm.DB, event *models.ProcessorEvent) (bool, error) {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(event)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *processorEventRepository) GetByEventID(eventID string) (*models.ProcessorEvent, error) {
+	var event models.ProcessorEvent
+	if err := r.db.Where("event_id = ?", eventID).First(&event).Error; err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
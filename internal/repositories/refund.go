@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	Create(tx *gorm.DB, refund *models.Refund) error
+	GetByRefundID(tx *gorm.DB, refundID string) (*models.Refund, error)
+	ListByPaymentID(paymentID uint) ([]*models.Refund, error)
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+func (r *refundRepository) Create(tx *gorm.DB, refund *models.Refund) error {
+	return tx.Create(refund).Error
+}
+
+func (r *refundRepository) GetByRefundID(tx *gorm.DB, refundID string) (*models.Refund, error) {
+	var refund models.Refund
+	if err := tx.Where("refund_id = ?", refundID).First(&refund).Error; err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+func (r *refundRepository) ListByPaymentID(paymentID uint) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	if err := r.db.Where("payment_id = ?", paymentID).Order("id").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
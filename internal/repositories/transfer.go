@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type TransferRepository interface {
+	Create(tx *gorm.DB, transfer *models.Transfer) error
+	GetByTransferID(transferID string) (*models.Transfer, error)
+}
+
+type transferRepository struct {
+	db *gorm.DB
+}
+
+func NewTransferRepository(db *gorm.DB) TransferRepository {
+	return &transferRepository{db: db}
+}
+
+func (r *transferRepository) Create(tx *gorm.DB, transfer *models.Transfer) error {
+	return tx.Create(transfer).Error
+}
+
+func (r *transferRepository) GetByTransferID(transferID string) (*models.Transfer, error) {
+	var transfer models.Transfer
+	if err := r.db.Where("transfer_id = ?", transferID).First(&transfer).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
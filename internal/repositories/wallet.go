@@ -1,16 +1,22 @@
 package repositories
 
 import (
+	"errors"
+
 	"payment-service/internal/models"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// ErrDuplicateWallet is returned by Create when the user already has a wallet in the currency.
+var ErrDuplicateWallet = errors.New("wallet already exists")
+
 type WalletRepository interface {
 	Create(tx *gorm.DB, wallet *models.Wallet) error
-	GetForUpdate(tx *gorm.DB, userID string) (*models.Wallet, error)
-	GetByUserId(userId string) (*models.Wallet, error)
+	GetForUpdate(tx *gorm.DB, userID string, currency string) (*models.Wallet, error)
+	GetByUserId(userId string, currency string) (*models.Wallet, error)
+	ListByUserId(userId string) ([]*models.Wallet, error)
 	UpdateBalance(tx *gorm.DB, wallet *models.Wallet) error
 }
 
@@ -22,33 +28,55 @@ func NewWalletRepository(db *gorm.DB) WalletRepository {
 	return &walletRepository{db: db}
 }
 
+// Create
+// insert the wallet unless the user already has one in the currency.
+// The unique index on user and currency makes the check-and-insert atomic, the loser of a race gets ErrDuplicateWallet.
 func (r *walletRepository) Create(tx *gorm.DB, wallet *models.Wallet) error {
-	return r.db.Create(wallet).Error
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "currency"}},
+		DoNothing: true,
+	}).Create(wallet)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDuplicateWallet
+	}
+	return nil
 }
 
 // GetForUpdate
 // lock the selected rows for the duration of the transaction.
 // This can be used in scenarios where you are preparing to update the rows and want to prevent other transactions from modifying them until your transaction is complete.
-func (r *walletRepository) GetForUpdate(tx *gorm.DB, userID string) (*models.Wallet, error) {
+func (r *walletRepository) GetForUpdate(tx *gorm.DB, userID string, currency string) (*models.Wallet, error) {
 	var wallet models.Wallet
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("user_id = ?", userID).
+		Where("user_id = ? AND currency = ?", userID, currency).
 		First(&wallet).Error; err != nil {
 		return nil, err
 	}
 	return &wallet, nil
 }
 
-func (r *walletRepository) GetByUserId(userID string) (*models.Wallet, error) {
+func (r *walletRepository) GetByUserId(userID string, currency string) (*models.Wallet, error) {
 	var wallet models.Wallet
-	if err := r.db.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := r.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error; err != nil {
 		return nil, err
 	}
 	return &wallet, nil
 }
 
+func (r *walletRepository) ListByUserId(userID string) ([]*models.Wallet, error) {
+	var wallets []*models.Wallet
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
 func (r *walletRepository) UpdateBalance(tx *gorm.DB, wallet *models.Wallet) error {
 	return tx.Model(wallet).Updates(map[string]interface{}{
-		"balance": wallet.Balance,
+		"balance":      wallet.Balance,
+		"held_balance": wallet.HeldBalance,
 	}).Error
 }
@@ -0,0 +1,188 @@
+package repositories
+
+import (
+	"time"
+
+	"payment-service/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookEndpointRepository interface {
+	Create(endpoint *models.WebhookEndpoint) error
+	GetByID(id uint) (*models.WebhookEndpoint, error)
+	GetAll() ([]*models.WebhookEndpoint, error)
+	ListActive() ([]*models.WebhookEndpoint, error)
+	Deactivate(id uint) error
+}
+
+type webhookEndpointRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookEndpointRepository(db *gorm.DB) WebhookEndpointRepository {
+	return &webhookEndpointRepository{db: db}
+}
+
+func (r *webhookEndpointRepository) Create(endpoint *models.WebhookEndpoint) error {
+	return r.db.Create(endpoint).Error
+}
+
+func (r *webhookEndpointRepository) GetByID(id uint) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := r.db.First(&endpoint, id).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookEndpointRepository) GetAll() ([]*models.WebhookEndpoint, error) {
+	var endpoints []*models.WebhookEndpoint
+	if err := r.db.Order("id").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *webhookEndpointRepository) ListActive() ([]*models.WebhookEndpoint, error) {
+	var endpoints []*models.WebhookEndpoint
+	if err := r.db.Where("active = ?", true).Order("id").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+func (r *webhookEndpointRepository) Deactivate(id uint) error {
+	result := r.db.Model(&models.WebhookEndpoint{}).Where("id = ?", id).Update("active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+type WebhookDeliveryRepository interface {
+	CreateIfAbsent(deliveries []*models.WebhookDelivery) error
+	GetByID(id uint) (*models.WebhookDelivery, error)
+	ListByEndpointID(endpointID uint) ([]*models.WebhookDelivery, error)
+	ClaimNext(visibilityTimeout time.Duration) (*models.WebhookDelivery, error)
+	RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error
+	Redeliver(id uint) (*models.WebhookDelivery, error)
+	RedeliverFailed(endpointID uint) (int64, error)
+}
+
+type webhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookDeliveryRepository(db *gorm.DB) WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{db: db}
+}
+
+// CreateIfAbsent
+// insert the deliveries, a delivery of the same event to the same endpoint that already exists is kept as it is.
+// Events are published at least once, so the same event may be handed over again.
+func (r *webhookDeliveryRepository) CreateIfAbsent(deliveries []*models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(deliveries).Error
+}
+
+// GetByID returns the delivery with its attempt log.
+func (r *webhookDeliveryRepository) GetByID(id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.Preload("AttemptLog", func(db *gorm.DB) *gorm.DB {
+		return db.Order("attempt")
+	}).First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *webhookDeliveryRepository) ListByEndpointID(endpointID uint) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	if err := r.db.Where("endpoint_id = ?", endpointID).Order("id DESC").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ClaimNext
+// claim the next due delivery, returns gorm.ErrRecordNotFound when there is none.
+// The claim pushes next_attempt_at out by the visibility timeout, so a delivery whose dispatcher died
+// becomes due again on its own.
+func (r *webhookDeliveryRepository) ClaimNext(visibilityTimeout time.Duration) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	now := time.Now()
+
+	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+			Order("next_attempt_at").
+			First(&delivery).Error; err != nil {
+			return err
+		}
+
+		delivery.Attempts++
+		delivery.NextAttemptAt = now.Add(visibilityTimeout)
+		return tx.Model(&delivery).Updates(map[string]interface{}{
+			"attempts":        delivery.Attempts,
+			"next_attempt_at": delivery.NextAttemptAt,
+		}).Error
+	}); err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RecordAttempt
+// append the attempt to the delivery log and store the outcome of the delivery in one transaction.
+func (r *webhookDeliveryRepository) RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(attempt).Error; err != nil {
+			return err
+		}
+		return tx.Model(delivery).Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"next_attempt_at":  delivery.NextAttemptAt,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+			"delivered_at":     delivery.DeliveredAt,
+		}).Error
+	})
+}
+
+// Redeliver
+// queue a delivery to be sent now with fresh attempts, whatever its status. The attempt log is kept.
+func (r *webhookDeliveryRepository) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          models.DeliveryPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.GetByID(id)
+}
+
+// RedeliverFailed
+// queue every failed delivery of an endpoint again, returns how many were queued.
+func (r *webhookDeliveryRepository) RedeliverFailed(endpointID uint) (int64, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("endpoint_id = ? AND status = ?", endpointID, models.DeliveryFailed).
+		Updates(map[string]interface{}{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+	"payment-service/internal/utils/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+var (
+	errIdempotencyKeyTooLong    = errors.New("idempotency key must not exceed 255 characters")
+	errIdempotencyKeyInProgress = errors.New("a request with the same idempotency key is still in progress")
+	errIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request")
+)
+
+type IdempotencyOptions struct {
+	// an in-progress record older than this is released for the next retry, its request most likely died.
+	// It must be longer than any request can run, or a retry would execute the request a second time.
+	StaleAfter time.Duration
+}
+
+func DefaultIdempotencyOptions() IdempotencyOptions {
+	return IdempotencyOptions{StaleAfter: 2 * time.Minute}
+}
+
+// Idempotency
+// make mutating requests safe to retry when the client sends an Idempotency-Key header.
+// The first request with a key is executed and its status code and response body are stored,
+// every retry with the same key and the same request gets a byte-identical replay.
+// Reusing a key with a different request is rejected with 422, and a retry that arrives while
+// the first request is still running is rejected with 409, until its record is older than opts.StaleAfter.
+// Requests without the header, and safe methods (GET, HEAD, OPTIONS), pass through untouched.
+func Idempotency(repo repositories.IdempotencyRepository, opts IdempotencyOptions) gin.HandlerFunc {
+	log := logger.Logger{}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			response.ValidationErrorResponse(c, errIdempotencyKeyTooLong)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.ValidationErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+		// restore the body so that the handler can bind it
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := &models.IdempotencyRecord{
+			IdempotencyKey: key,
+			Method:         c.Request.Method,
+			Path:           c.Request.URL.Path,
+			RequestHash:    fingerprintRequest(c.Request.Method, c.Request.URL.Path, body),
+		}
+
+		created, err := repo.CreateIfAbsent(record)
+		if err != nil {
+			response.InternalServerErrorResponse(c, err)
+			c.Abort()
+			return
+		}
+
+		if !created {
+			replayOrReject(c, repo, record, opts, log)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		c.Next()
+
+		// server errors are not stored, the client should be able to retry with the same key
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := repo.DeleteByKey(key); err != nil {
+				log.Error(err, "Failed to release idempotency key")
+			}
+			return
+		}
+
+		record.StatusCode = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.SaveResponse(record); err != nil {
+			log.Error(err, "Failed to store idempotent response")
+		}
+	}
+}
+
+// replayOrReject answers a request whose key was already claimed by an earlier request.
+func replayOrReject(c *gin.Context, repo repositories.IdempotencyRepository, record *models.IdempotencyRecord, opts IdempotencyOptions, log logger.Logger) {
+	existing, err := repo.GetByKey(record.IdempotencyKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// the earlier request failed with a server error and released the key in between
+			response.ErrorCodeResponse(c, http.StatusConflict, response.CodeIdempotencyKeyInProgress, "Request is being processed, please retry", errIdempotencyKeyInProgress)
+			return
+		}
+		response.InternalServerErrorResponse(c, err)
+		return
+	}
+
+	if existing.RequestHash != record.RequestHash {
+		response.ErrorCodeResponse(c, http.StatusUnprocessableEntity, response.CodeIdempotencyKeyConflict, "Idempotency key reused", errIdempotencyKeyMismatch)
+		return
+	}
+
+	if !existing.IsCompleted() {
+		// the earlier request most likely died without releasing the key, free it for the next retry
+		if time.Since(existing.CreatedAt) > opts.StaleAfter {
+			if err := repo.DeleteByKey(existing.IdempotencyKey); err != nil {
+				log.Error(err, "Failed to release stale idempotency key")
+			}
+		}
+		response.ErrorCodeResponse(c, http.StatusConflict, response.CodeIdempotencyKeyInProgress, "Request is being processed, please retry", errIdempotencyKeyInProgress)
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+}
+
+// fingerprintRequest hashes the method, path and body of a request.
+// JSON bodies are canonicalized first, so that key order and whitespace do not change the fingerprint.
+func fingerprintRequest(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(canonicalJSON(body))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func canonicalJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+
+	// encoding/json sorts map keys, which makes the output canonical
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// responseRecorder tees everything written to the client into a buffer.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"payment-service/internal/handlers"
+	"payment-service/internal/repositories"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,6 +10,16 @@ import (
 func RegisterRoutes(
 	paymentHandler *handlers.PaymentHandler,
 	userHandler *handlers.UserHandler,
+	metricsHandler *handlers.MetricsHandler,
+	webhookHandler *handlers.WebhookHandler,
+	webhookEndpointHandler *handlers.WebhookEndpointHandler,
+	ledgerHandler *handlers.LedgerHandler,
+	refundHandler *handlers.RefundHandler,
+	depositHandler *handlers.DepositHandler,
+	transferHandler *handlers.TransferHandler,
+	fxHandler *handlers.FxHandler,
+	idempotencyRepo repositories.IdempotencyRepository,
+	idempotencyOptions IdempotencyOptions,
 ) *gin.Engine {
 	router := gin.Default()
 
@@ -20,16 +31,65 @@ func RegisterRoutes(
 		})
 	})
 
+	router.GET("/metrics/locks", metricsHandler.GetLockMetrics)
+
+	// called by the payment processor, authenticated by the webhook signature and deduplicated by event ID,
+	// so it is registered without the Idempotency-Key middleware
+	router.POST("/api/v1/webhooks/processor", webhookHandler.ProcessorWebhook)
+
 	v1 := router.Group("/api/v1")
+	// every client-facing mutating endpoint under v1 honors the Idempotency-Key header
+	v1.Use(Idempotency(idempotencyRepo, idempotencyOptions))
 	{
 		v1.POST("/pay", paymentHandler.ProcessPayment)
 		v1.GET("/payments/transaction/:transactionId", paymentHandler.GetPaymentByTransactionID)
-		v1.GET("/payments", paymentHandler.GetAll)
+		v1.GET("/payments", paymentHandler.ListPayments)
+		v1.GET("/payments/export", paymentHandler.ExportPayments)
+		v1.GET("/payments/:transactionId/history", paymentHandler.GetPaymentHistory)
+		v1.POST("/payments/:transactionId/cancel", paymentHandler.CancelPayment)
+		v1.POST("/payments/:transactionId/refunds", refundHandler.CreateRefund)
+		v1.GET("/payments/:transactionId/refunds", refundHandler.GetRefunds)
+
+		transferGrp := v1.Group("/transfers")
+		{
+			transferGrp.POST("", transferHandler.Transfer)
+			transferGrp.GET("/:transferId", transferHandler.GetTransfer)
+		}
+
+		v1.POST("/fx/quotes", fxHandler.CreateQuote)
+
+		ledgerGrp := v1.Group("/ledger")
+		{
+			ledgerGrp.GET("/reconciliations", ledgerHandler.GetReconciliations)
+			ledgerGrp.POST("/reconciliations", ledgerHandler.Reconcile)
+		}
+
+		endpointGrp := v1.Group("/webhook-endpoints")
+		{
+			endpointGrp.POST("", webhookEndpointHandler.Register)
+			endpointGrp.GET("", webhookEndpointHandler.GetAll)
+			endpointGrp.GET("/:endpointId", webhookEndpointHandler.GetDetail)
+			endpointGrp.DELETE("/:endpointId", webhookEndpointHandler.Deactivate)
+			endpointGrp.GET("/:endpointId/deliveries", webhookEndpointHandler.GetDeliveries)
+			endpointGrp.POST("/:endpointId/redeliver", webhookEndpointHandler.RedeliverFailed)
+		}
+
+		deliveryGrp := v1.Group("/webhook-deliveries")
+		{
+			deliveryGrp.GET("/:deliveryId", webhookEndpointHandler.GetDelivery)
+			deliveryGrp.POST("/:deliveryId/redeliver", webhookEndpointHandler.Redeliver)
+		}
 
 		userGrp := v1.Group("/users")
 		{
 			userGrp.GET("", userHandler.GetAll)
 			userGrp.GET("/:userId", userHandler.GetDetail)
+			userGrp.GET("/:userId/wallets", userHandler.GetWallets)
+			userGrp.POST("/:userId/wallets", userHandler.CreateWallet)
+			userGrp.GET("/:userId/payments", userHandler.GetPayments)
+			userGrp.GET("/:userId/wallet/ledger", ledgerHandler.GetWalletLedger)
+			userGrp.POST("/:userId/wallet/deposits", depositHandler.Deposit)
+			userGrp.GET("/:userId/wallet/deposits", depositHandler.GetDeposits)
 			userGrp.POST("/generate", userHandler.Generate)
 		}
 	}
@@ -0,0 +1,133 @@
+package routes_test
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-service/internal/database"
+	"payment-service/internal/repositories"
+	"payment-service/internal/routes"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var testDB *gorm.DB
+
+func TestMain(m *testing.M) {
+	var err error
+	testDB, _, err = database.InitTestDatabase()
+	if err != nil {
+		log.Fatalf("failed to init test DB: %v", err)
+	}
+
+	code := m.Run()
+
+	database.TerminateTestDatabase()
+
+	os.Exit(code)
+}
+
+func newIdempotentRouter(calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(routes.Idempotency(repositories.NewIdempotencyRepository(testDB), routes.DefaultIdempotencyOptions()))
+	router.POST("/pay", func(c *gin.Context) {
+		*calls++
+		c.JSON(http.StatusCreated, gin.H{"call": *calls})
+	})
+	return router
+}
+
+func send(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/pay", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(routes.IdempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	_ = database.CleanTestData()
+	calls := 0
+	router := newIdempotentRouter(&calls)
+
+	first := send(router, "key-1", `{"amount": 100, "user_id": "u1"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	t.Run("Retry with same key and payload replays the stored response", func(t *testing.T) {
+		// same payload with different key order and whitespace
+		second := send(router, "key-1", `{"user_id":"u1","amount":100}`)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get(routes.IdempotentReplayedHeader))
+		assert.Equal(t, 1, calls, "handler should only be executed once")
+	})
+
+	t.Run("Reusing key with a different payload is rejected", func(t *testing.T) {
+		conflict := send(router, "key-1", `{"user_id":"u1","amount":200}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, conflict.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Requests without key are not deduplicated", func(t *testing.T) {
+		send(router, "", `{"user_id":"u1","amount":100}`)
+		send(router, "", `{"user_id":"u1","amount":100}`)
+		assert.Equal(t, 3, calls)
+	})
+}
+
+func TestIdempotencyStaleInProgressKey(t *testing.T) {
+	_ = database.CleanTestData()
+	repo := repositories.NewIdempotencyRepository(testDB)
+	body := `{"user_id":"u1","amount":100}`
+
+	tests := []struct {
+		name       string
+		key        string
+		staleAfter time.Duration
+		released   bool
+	}{
+		{name: "In-progress key younger than the threshold is kept", key: "key-fresh", staleAfter: time.Minute, released: false},
+		{name: "In-progress key older than the threshold is released", key: "key-stale", staleAfter: 0, released: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(routes.Idempotency(repo, routes.IdempotencyOptions{StaleAfter: tt.staleAfter}))
+
+			var retry *httptest.ResponseRecorder
+			router.POST("/pay", func(c *gin.Context) {
+				// a retry arrives while the first request is still running
+				if retry == nil {
+					retry = send(router, tt.key, body)
+				}
+				c.JSON(http.StatusCreated, gin.H{})
+			})
+
+			first := send(router, tt.key, body)
+			assert.Equal(t, http.StatusCreated, first.Code)
+			require.NotNil(t, retry)
+			assert.Equal(t, http.StatusConflict, retry.Code)
+
+			_, err := repo.GetByKey(tt.key)
+			if tt.released {
+				assert.ErrorIs(t, err, gorm.ErrRecordNotFound, "a stale key should be released for the next retry")
+			} else {
+				assert.NoError(t, err, "a key in progress should not be released before the threshold")
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/redis"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+type DepositService interface {
+	Deposit(ctx context.Context, userID string, req *models.DepositRequest) (*models.Deposit, bool, error)
+	GetDeposits(userID string) ([]*models.Deposit, error)
+}
+
+type depositService struct {
+	logger      logger.Logger
+	db          *gorm.DB
+	locker      redis.Locker
+	depositRepo repositories.DepositRepository
+	walletRepo  repositories.WalletRepository
+	fenceRepo   repositories.LockFenceRepository
+	ledgerRepo  repositories.LedgerRepository
+}
+
+func NewDepositService(
+	db *gorm.DB,
+	depositRepo repositories.DepositRepository,
+	walletRepo repositories.WalletRepository,
+	fenceRepo repositories.LockFenceRepository,
+	ledgerRepo repositories.LedgerRepository,
+	locker redis.Locker,
+) DepositService {
+	return &depositService{
+		logger:      logger.Logger{},
+		db:          db,
+		locker:      locker,
+		depositRepo: depositRepo,
+		walletRepo:  walletRepo,
+		fenceRepo:   fenceRepo,
+		ledgerRepo:  ledgerRepo,
+	}
+}
+
+// Deposit credits the amount of the request to the user's wallet in its currency, like ProcessPayment it holds the lock of the
+// deposit ID and records the deposit only if the lock's fencing token is still the newest one for the key.
+// The deposit is recorded as pending first, then completed in a second transaction that locks the deposit and the
+// wallet rows, credits the wallet and posts the credit to the ledger.
+// It is idempotent by deposit ID: a deposit ID that was already used by the same user with the same payload returns
+// the existing deposit and false, after completing it if it was left pending (e.g. by a crash); with a different
+// user or payload it fails with ErrDepositIDConflict. A deposit ID that is locked by a concurrent request returns
+// the deposit if it is already recorded, and fails with ErrDepositInProgress otherwise.
+func (s *depositService) Deposit(ctx context.Context, userID string, req *models.DepositRequest) (*models.Deposit, bool, error) {
+	code := currency.OrDefault(req.Currency)
+	if _, err := getWallet(s.walletRepo, userID, code); err != nil {
+		return nil, false, err
+	}
+
+	hash := req.Hash(userID)
+	lockKey := "deposit:" + req.DepositID
+	lock, err := s.locker.Acquire(ctx, lockKey)
+	if err != nil {
+		if errors.Is(err, redis.ErrLockNotAcquired) {
+			existing, err := s.getExisting(req.DepositID, hash)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing == nil {
+				return nil, false, ErrDepositInProgress
+			}
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+	defer func() {
+		if err := s.locker.Unlock(ctx, lock); err != nil {
+			s.logger.Error(err, "Failed to release the lock")
+		}
+	}()
+
+	existing, err := s.getExisting(req.DepositID, hash)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		if existing.Status != models.DepositPending {
+			return existing, false, nil
+		}
+		deposit, err := s.completeDeposit(existing.ID)
+		return deposit, false, err
+	}
+
+	deposit := &models.Deposit{
+		DepositID:   req.DepositID,
+		UserID:      userID,
+		Amount:      req.Amount,
+		Currency:    code,
+		Status:      models.DepositPending,
+		RequestHash: hash,
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.fenceRepo.Advance(tx, lockKey, lock.Fence); err != nil {
+			return err
+		}
+		return s.depositRepo.Create(tx, deposit)
+	}); err != nil {
+		return nil, false, err
+	}
+
+	deposit, err = s.completeDeposit(deposit.ID)
+	return deposit, true, err
+}
+
+// completeDeposit credits a pending deposit to the wallet and marks it completed, with the ledger posting of the
+// credit, in one transaction. A deposit that is no longer pending is returned unchanged.
+func (s *depositService) completeDeposit(id uint) (*models.Deposit, error) {
+	var deposit *models.Deposit
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		deposit, err = s.depositRepo.GetForUpdate(tx, id)
+		if err != nil {
+			return err
+		}
+		if deposit.Status != models.DepositPending {
+			return nil
+		}
+
+		wallet, err := s.walletRepo.GetForUpdate(tx, deposit.UserID, deposit.Currency)
+		if err != nil {
+			return err
+		}
+		if err := wallet.Credit(deposit.Amount); err != nil {
+			return err
+		}
+		if err := s.walletRepo.UpdateBalance(tx, wallet); err != nil {
+			return err
+		}
+
+		posting := walletPosting(fmt.Sprintf("deposit:%d", deposit.ID), wallet, models.Credit, deposit.Amount,
+			models.AccountFunding, "deposit "+deposit.DepositID, nil)
+		if err := s.ledgerRepo.Post(tx, posting); err != nil {
+			return err
+		}
+
+		deposit.Status = models.DepositCompleted
+		return s.depositRepo.UpdateStatus(tx, deposit, models.DepositPending)
+	}); err != nil {
+		s.logger.Error(err, "Failed to complete deposit")
+		return nil, err
+	}
+
+	return deposit, nil
+}
+
+// getExisting returns the deposit with the deposit ID, or nil if there is none.
+// It fails with ErrDepositIDConflict if the deposit was created from a different request.
+func (s *depositService) getExisting(depositID, hash string) (*models.Deposit, error) {
+	existing, err := s.depositRepo.GetByDepositID(depositID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if existing.RequestHash != hash {
+		return nil, ErrDepositIDConflict
+	}
+	return existing, nil
+}
+
+func (s *depositService) GetDeposits(userID string) ([]*models.Deposit, error) {
+	wallets, err := s.walletRepo.ListByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.depositRepo.ListByUserID(userID)
+}
@@ -0,0 +1,42 @@
+package services
+
+import "errors"
+
+var (
+	// ErrIdempotencyKeyConflict is returned when a transaction_id is reused with a different request payload.
+	ErrIdempotencyKeyConflict = errors.New("transaction_id was already used with a different request payload")
+
+	// ErrPaymentNotCancellable is returned when cancelling a payment that is no longer pending.
+	ErrPaymentNotCancellable = errors.New("payment is no longer pending and cannot be cancelled")
+
+	// ErrRefundIDConflict is returned when a refund_id is reused with a different request payload or payment.
+	ErrRefundIDConflict = errors.New("refund_id was already used with a different request payload")
+	// ErrPaymentNotRefundable is returned when refunding a payment that did not complete or was fully refunded.
+	ErrPaymentNotRefundable = errors.New("payment is not refundable")
+	// ErrRefundExceedsPayment is returned when a refund would bring the refunded total above the payment amount.
+	ErrRefundExceedsPayment = errors.New("refund amount exceeds the refundable amount of the payment")
+	// ErrRefundPrecision is returned when a refund amount has more decimal places than the payment currency allows.
+	ErrRefundPrecision = errors.New("refund amount has more decimal places than the payment currency allows")
+
+	// ErrDepositIDConflict is returned when a deposit_id is reused with a different request payload or user.
+	ErrDepositIDConflict = errors.New("deposit_id was already used with a different request payload")
+	// ErrDepositInProgress is returned when the same deposit_id is being processed by another request.
+	ErrDepositInProgress = errors.New("a deposit with this deposit_id is in progress")
+
+	// ErrTransferIDConflict is returned when a transfer_id is reused with a different request payload.
+	ErrTransferIDConflict = errors.New("transfer_id was already used with a different request payload")
+	// ErrTransferInProgress is returned when the same transfer_id is being processed by another request.
+	ErrTransferInProgress = errors.New("a transfer with this transfer_id is in progress")
+	// ErrSelfTransfer is returned when the sender and the recipient of a transfer are the same user.
+	ErrSelfTransfer = errors.New("cannot transfer to the same wallet")
+
+	// ErrCurrencyMismatch is returned when the user has no wallet in the currency of the request.
+	ErrCurrencyMismatch = errors.New("the user has no wallet in the requested currency")
+	// ErrWalletExists is returned when opening a wallet in a currency the user already has a wallet in.
+	ErrWalletExists = errors.New("the user already has a wallet in this currency")
+
+	// ErrQuoteInvalid is returned when a payment names a quote that does not exist or is for other currencies.
+	ErrQuoteInvalid = errors.New("quote_id does not match a quote for the payment currencies")
+	// ErrQuoteExpired is returned when a payment names a quote whose validity is over.
+	ErrQuoteExpired = errors.New("quote has expired")
+)
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"payment-service/internal/fx"
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type FxService interface {
+	CreateQuote(ctx context.Context, req *models.FxQuoteRequest) (*models.FxQuote, error)
+	Convert(ctx context.Context, payment *models.Payment, walletCurrency string, quoteID string) error
+}
+
+type fxService struct {
+	options   fx.Options
+	quoteRepo repositories.FxQuoteRepository
+	provider  fx.RateProvider
+}
+
+func NewFxService(options fx.Options, quoteRepo repositories.FxQuoteRepository, provider fx.RateProvider) FxService {
+	return &fxService{
+		options:   options,
+		quoteRepo: quoteRepo,
+		provider:  provider,
+	}
+}
+
+// CreateQuote records the current rate with the spread for paying in the request currency from a wallet in the
+// request wallet currency. A payment that names the quote within the quote TTL is converted at that rate.
+func (s *fxService) CreateQuote(ctx context.Context, req *models.FxQuoteRequest) (*models.FxQuote, error) {
+	rate, err := s.provider.Rate(ctx, req.Currency, req.WalletCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &models.FxQuote{
+		QuoteID:        "quote_" + uuid.NewString(),
+		Currency:       req.Currency,
+		WalletCurrency: req.WalletCurrency,
+		Rate:           rate,
+		Spread:         s.options.Spread,
+		CustomerRate:   rate.Mul(decimal.NewFromInt(1).Add(s.options.Spread)),
+		ExpiresAt:      time.Now().Add(s.options.QuoteTTL),
+	}
+	if err := s.quoteRepo.Create(quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// Convert writes the FX snapshot of a new payment that is paid from the wallet in walletCurrency.
+// With a quote ID the quoted rate and spread are used, the quote must be for the same currencies and not expired
+// (ErrQuoteInvalid, ErrQuoteExpired), and an empty walletCurrency is the quote's. Without one, a payment from a wallet
+// in another currency takes the provider's current rate with the configured spread, and a payment with an empty
+// walletCurrency is paid from the wallet in the payment currency without conversion.
+func (s *fxService) Convert(ctx context.Context, payment *models.Payment, walletCurrency string, quoteID string) error {
+	rate, spread := decimal.NewFromInt(1), decimal.Zero
+	switch {
+	case quoteID != "":
+		quote, err := s.quoteRepo.GetByQuoteID(quoteID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrQuoteInvalid
+			}
+			return err
+		}
+		if walletCurrency == "" {
+			walletCurrency = quote.WalletCurrency
+		}
+		if quote.Currency != payment.Currency || quote.WalletCurrency != walletCurrency {
+			return ErrQuoteInvalid
+		}
+		if time.Now().After(quote.ExpiresAt) {
+			return ErrQuoteExpired
+		}
+		rate, spread = quote.Rate, quote.Spread
+		payment.FxQuoteID = quote.QuoteID
+	case walletCurrency != "" && walletCurrency != payment.Currency:
+		current, err := s.provider.Rate(ctx, payment.Currency, walletCurrency)
+		if err != nil {
+			return err
+		}
+		rate, spread = current, s.options.Spread
+	default:
+		walletCurrency = payment.Currency
+	}
+
+	payment.WalletCurrency = walletCurrency
+	payment.FxRate = rate
+	payment.FxSpread = spread
+	payment.WalletAmount = fx.Convert(payment.Amount, rate, spread, walletCurrency)
+	return nil
+}
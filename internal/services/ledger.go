@@ -0,0 +1,226 @@
+package services
+
+import (
+	"fmt"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// WalletLedger is a wallet with its ledger entries and the balance derived from them.
+type WalletLedger struct {
+	WalletID      uint                  `json:"wallet_id"`
+	UserID        string                `json:"user_id"`
+	StoredBalance decimal.Decimal       `json:"stored_balance"`
+	LedgerBalance decimal.Decimal       `json:"ledger_balance"`
+	Reconciled    bool                  `json:"reconciled"`
+	Entries       []*models.LedgerEntry `json:"entries"`
+}
+
+type LedgerService interface {
+	GetWalletLedger(userID string, currency string) (*WalletLedger, error)
+	BackfillWallets() (int, error)
+	Reconcile() ([]*models.WalletReconciliation, error)
+	GetReconciliations(limit int) ([]*models.WalletReconciliation, error)
+}
+
+type ledgerService struct {
+	logger     logger.Logger
+	db         *gorm.DB
+	ledgerRepo repositories.LedgerRepository
+	walletRepo repositories.WalletRepository
+}
+
+func NewLedgerService(
+	db *gorm.DB,
+	ledgerRepo repositories.LedgerRepository,
+	walletRepo repositories.WalletRepository,
+) LedgerService {
+	return &ledgerService{
+		logger:     logger.Logger{},
+		db:         db,
+		ledgerRepo: ledgerRepo,
+		walletRepo: walletRepo,
+	}
+}
+
+func (s *ledgerService) GetWalletLedger(userID string, currency string) (*WalletLedger, error) {
+	wallet, err := s.walletRepo.GetByUserId(userID, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.ledgerRepo.ListByAccount(models.WalletAccount(userID, currency))
+	if err != nil {
+		return nil, err
+	}
+
+	balance := decimal.Zero
+	for _, entry := range entries {
+		balance = balance.Add(entry.SignedAmount())
+	}
+
+	return &WalletLedger{
+		WalletID:      wallet.ID,
+		UserID:        wallet.UserID,
+		StoredBalance: wallet.Balance,
+		LedgerBalance: balance,
+		Reconciled:    wallet.Balance.Equal(balance),
+		Entries:       entries,
+	}, nil
+}
+
+// BackfillWallets posts the history of every wallet created before the ledger existed: an opening balance funded
+// from outside, and a debit per settled payment charged to the wallet, so that its ledger balance equals the stored
+// balance. Wallets that already have entries are left alone, so running it again does nothing.
+// It returns the number of wallets backfilled.
+func (s *ledgerService) BackfillWallets() (int, error) {
+	wallets, err := s.ledgerRepo.ListUnpostedWallets()
+	if err != nil {
+		return 0, err
+	}
+
+	backfilled := 0
+	for _, unposted := range wallets {
+		posted := false
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			// lock the wallet and check again, a payment or another replica may have posted in the meantime
+			wallet, err := s.walletRepo.GetForUpdate(tx, unposted.UserID, unposted.Currency)
+			if err != nil {
+				return err
+			}
+			hasEntries, err := s.ledgerRepo.HasWalletEntries(tx, wallet.ID)
+			if err != nil || hasEntries {
+				return err
+			}
+
+			payments, err := s.ledgerRepo.ListUnpostedPayments(tx, wallet)
+			if err != nil {
+				return err
+			}
+
+			// the opening balance is what the wallet held before the payments were charged
+			opening := wallet.Balance
+			for _, payment := range payments {
+				opening = opening.Add(payment.ChargedAmount())
+			}
+			if opening.IsPositive() {
+				posting := walletPosting(fmt.Sprintf("wallet:%d:opening", wallet.ID), wallet, models.Credit, opening,
+					models.AccountFunding, "opening balance", nil)
+				if err := s.ledgerRepo.Post(tx, posting); err != nil {
+					return err
+				}
+				posted = true
+			}
+
+			for _, payment := range payments {
+				posting := walletPosting(fmt.Sprintf("payment:%d", payment.ID), wallet, models.Debit, payment.ChargedAmount(),
+					models.AccountProcessorSettlement, "payment "+payment.TransactionID, &payment.ID)
+				if err := s.ledgerRepo.Post(tx, posting); err != nil {
+					return err
+				}
+				posted = true
+			}
+			return nil
+		}); err != nil {
+			return backfilled, err
+		}
+
+		if posted {
+			backfilled++
+		}
+	}
+	return backfilled, nil
+}
+
+// Reconcile flags every wallet whose stored balance differs from the sum of its ledger entries, and returns them.
+// A mismatch is recorded in wallet_reconciliations only when it is new or changed since the wallet was last recorded.
+func (s *ledgerService) Reconcile() ([]*models.WalletReconciliation, error) {
+	mismatches, err := s.ledgerRepo.FindMismatchedWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := s.ledgerRepo.SaveReconciliations(mismatches)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mismatch := range recorded {
+		s.logger.Info(fmt.Sprintf("[Reconciliation] Wallet %d of user %s: stored balance %s, ledger balance %s",
+			mismatch.WalletID, mismatch.UserID, mismatch.StoredBalance, mismatch.LedgerBalance))
+	}
+	return mismatches, nil
+}
+
+func (s *ledgerService) GetReconciliations(limit int) ([]*models.WalletReconciliation, error) {
+	return s.ledgerRepo.ListReconciliations(limit)
+}
+
+// walletPosting builds the balanced posting of a wallet balance change: the wallet account is debited or
+// credited with the amount, and the counter account gets the opposite entry.
+func walletPosting(
+	reference string,
+	wallet *models.Wallet,
+	direction models.LedgerDirection,
+	amount decimal.Decimal,
+	counterAccount string,
+	description string,
+	paymentID *uint,
+) []*models.LedgerEntry {
+	counterDirection := models.Credit
+	if direction == models.Credit {
+		counterDirection = models.Debit
+	}
+
+	return []*models.LedgerEntry{
+		{
+			Reference:   reference,
+			Account:     models.WalletAccount(wallet.UserID, wallet.Currency),
+			WalletID:    &wallet.ID,
+			PaymentID:   paymentID,
+			Direction:   direction,
+			Amount:      amount,
+			Currency:    wallet.Currency,
+			Description: description,
+		},
+		{
+			Reference:   reference,
+			Account:     counterAccount,
+			PaymentID:   paymentID,
+			Direction:   counterDirection,
+			Amount:      amount,
+			Currency:    wallet.Currency,
+			Description: description,
+		},
+	}
+}
+
+// transferPosting builds the balanced posting of a transfer: the sender's wallet account is debited and the
+// recipient's wallet account is credited with the amount. Both wallets are in the same currency.
+func transferPosting(reference string, from, to *models.Wallet, amount decimal.Decimal, description string) []*models.LedgerEntry {
+	return []*models.LedgerEntry{
+		{
+			Reference:   reference,
+			Account:     models.WalletAccount(from.UserID, from.Currency),
+			WalletID:    &from.ID,
+			Direction:   models.Debit,
+			Amount:      amount,
+			Currency:    from.Currency,
+			Description: description,
+		},
+		{
+			Reference:   reference,
+			Account:     models.WalletAccount(to.UserID, to.Currency),
+			WalletID:    &to.ID,
+			Direction:   models.Credit,
+			Amount:      amount,
+			Currency:    to.Currency,
+			Description: description,
+		},
+	}
+}
@@ -1,10 +1,13 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math/rand"
+	"payment-service/internal/currency"
 	"payment-service/internal/models"
+	"payment-service/internal/processor"
 	"payment-service/internal/redis"
 	"payment-service/internal/repositories"
 	"payment-service/internal/utils/logger"
@@ -17,45 +20,88 @@ import (
 type PaymentService interface {
 	ProcessPayment(ctx *gin.Context, req *models.PaymentRequest) (*models.Payment, error)
 	GetPaymentByTransactionID(txId string) (*models.Payment, error)
-	GetAll() ([]*models.Payment, error)
+	GetPaymentHistory(txId string) ([]*models.PaymentStatusHistory, error)
+	ListPayments(filter *models.PaymentFilter, page *models.PageQuery) ([]*models.Payment, *models.Page, error)
+	ExportPayments(ctx context.Context, filter *models.PaymentFilter, fn func(payment *models.Payment) error) error
+	ExecutePayment(ctx context.Context, paymentID uint) error
+	FailPayment(paymentID uint, reason string) error
+	CancelPayment(txId string) (*models.Payment, error)
+	ApplyProcessorEvent(event *models.ProcessorEventRequest) (*models.Payment, bool, error)
 }
 
 type paymentService struct {
 	logger      logger.Logger
 	db          *gorm.DB
-	lockManager *redis.LockManager
+	locker      redis.Locker
 	paymentRepo repositories.PaymentRepository
 	walletRepo  repositories.WalletRepository
+	fenceRepo   repositories.LockFenceRepository
+	jobRepo     repositories.PaymentJobRepository
+	eventRepo   repositories.ProcessorEventRepository
+	outboxRepo  repositories.OutboxEventRepository
+	ledgerRepo  repositories.LedgerRepository
+	processor   processor.PaymentProcessor
+	fxService   FxService
 }
 
 func NewPaymentService(
 	db *gorm.DB,
 	paymentRepo repositories.PaymentRepository,
 	walletRepo repositories.WalletRepository,
+	fenceRepo repositories.LockFenceRepository,
+	jobRepo repositories.PaymentJobRepository,
+	eventRepo repositories.ProcessorEventRepository,
+	outboxRepo repositories.OutboxEventRepository,
+	ledgerRepo repositories.LedgerRepository,
+	locker redis.Locker,
+	paymentProcessor processor.PaymentProcessor,
+	fxService FxService,
 ) PaymentService {
 	return &paymentService{
 		logger:      logger.Logger{},
 		db:          db,
-		lockManager: redis.NewLockManager(),
+		locker:      locker,
 		paymentRepo: paymentRepo,
 		walletRepo:  walletRepo,
+		fenceRepo:   fenceRepo,
+		jobRepo:     jobRepo,
+		eventRepo:   eventRepo,
+		outboxRepo:  outboxRepo,
+		ledgerRepo:  ledgerRepo,
+		processor:   paymentProcessor,
+		fxService:   fxService,
 	}
 }
 
 // ProcessPayment handles a user's payment request in a safe and idempotent manner.
-// It first acquires a lock using the transaction ID to prevent duplicate processing.
-// If the payment with the same transaction ID already exists, it returns the existing record.
-// The function validates the user's wallet balance before creating a new payment record.
-// The payment status is initially set to Pending, and the actual processing is performed asynchronously
-// via simulatePaymentProcessing, which updates the payment status and wallet balance if successful.
+// It first acquires a lock using the transaction ID to prevent duplicate processing,
+// the payment record is only written if the lock's fencing token is still the newest one for the key.
+// If the payment with the same transaction ID already exists, it returns the existing record,
+// or ErrIdempotencyKeyConflict when the existing record was created from a different request payload.
+// The amount is reserved in the user's wallet in the payment currency under a row lock, in the same transaction that
+// creates the payment record, so it fails with models.ErrInsufficientFunds if the available balance does not cover it,
+// and with ErrCurrencyMismatch if the user has no wallet in the currency.
+// A payment paid from a wallet in another currency, or with a quote, is converted by the FX service first,
+// the amount reserved is then the converted one, see FxService.Convert.
+// The payment status is initially set to Pending, and a payment job is enqueued in the same transaction.
+// The actual processing is performed asynchronously by the payment worker through ExecutePayment,
+// which updates the payment status and wallet balance if successful.
 // Any errors encountered during validation, record creation, or wallet retrieval are returned immediately.
 func (s *paymentService) ProcessPayment(ctx *gin.Context, req *models.PaymentRequest) (*models.Payment, error) {
 	idempotencyKey := req.TransactionID
-	if _, ok := s.lockManager.TryLock(idempotencyKey); !ok {
-		s.logger.Info("Payment processing, failed to acquired the lock...")
-		return s.getOrNil(req)
+	lock, err := s.locker.Acquire(ctx, idempotencyKey)
+	if err != nil {
+		if errors.Is(err, redis.ErrLockNotAcquired) {
+			s.logger.Info("Payment processing, failed to acquired the lock...")
+			return s.getOrNil(req)
+		}
+		return nil, err
 	}
-	defer s.lockManager.Unlock(idempotencyKey)
+	defer func() {
+		if err := s.locker.Unlock(ctx, lock); err != nil {
+			s.logger.Error(err, "Failed to release the lock")
+		}
+	}()
 
 	time.Sleep(1 * time.Second)
 
@@ -66,77 +112,367 @@ func (s *paymentService) ProcessPayment(ctx *gin.Context, req *models.PaymentReq
 		return exist, nil
 	}
 
-	// Start processing
-	wallet, err := s.walletRepo.GetByUserId(req.UserID)
-	if err != nil {
-		return nil, err
-	}
-
-	if req.Amount.GreaterThan(wallet.Balance) {
-		return nil, errors.New("insufficient balance")
-	}
-
 	// Create payment record
 	payment := &models.Payment{
 		UserID:        req.UserID,
 		Amount:        req.Amount,
+		Currency:      currency.OrDefault(req.Currency),
 		TransactionID: req.TransactionID,
 		Status:        models.StatusPending,
+		RequestHash:   req.Hash(),
+		FundsHeld:     true,
 	}
-
-	if err := s.paymentRepo.Create(payment); err != nil {
+	if err := s.fxService.Convert(ctx, payment, req.WalletCurrency, req.QuoteID); err != nil {
 		return nil, err
 	}
 
-	// Simulate payment processing
-	go s.simulatePaymentProcessing(payment)
+	// the fencing token is checked in the same transaction, a holder whose lock expired while it was
+	// stalled is rejected instead of writing after the next holder
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.fenceRepo.Advance(tx, idempotencyKey, lock.Fence); err != nil {
+			return err
+		}
+
+		// the amount is reserved under the wallet row lock, concurrent payments of the same user cannot overspend
+		wallet, err := s.walletRepo.GetForUpdate(tx, req.UserID, payment.ChargedCurrency())
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrCurrencyMismatch
+			}
+			return err
+		}
+		if err := wallet.Reserve(payment.ChargedAmount()); err != nil {
+			return err
+		}
+		if err := s.walletRepo.UpdateBalance(tx, wallet); err != nil {
+			return err
+		}
+
+		if err := s.paymentRepo.Create(tx, payment); err != nil {
+			return err
+		}
+
+		// the job survives restarts, the worker picks it up even if this process dies right now
+		return s.jobRepo.Enqueue(tx, &models.PaymentJob{
+			PaymentID: payment.ID,
+			Status:    models.JobQueued,
+			RunAt:     time.Now(),
+		})
+	}); err != nil {
+		return nil, err
+	}
 
 	s.logger.Info("Payment Executed")
 	return payment, nil
 }
 
-// simulatePaymentProcessing simulates the processing of a payment for testing or demo purposes.
-// It randomly determines the payment outcome with a 90% chance of success and a 10% chance of failure.
-// The function also simulates a processing delay between 1 to 3 seconds.
-// If the payment succeeds, it updates the user's wallet balance within a database transaction.
-func (s *paymentService) simulatePaymentProcessing(payment *models.Payment) {
-	// Simulate processing time (1-3 seconds)
-	time.Sleep(time.Duration(1+rand.Intn(3)) * time.Second)
+// ExecutePayment processes a pending payment through the payment processor, it is called by the payment worker for every job.
+// The amount is authorized and captured, then the payment is settled as completed and the wallet is debited.
+// A declined authorization settles the payment as failed.
+// It is idempotent: a payment that already reached a terminal status is left untouched, and processor calls
+// use the transaction ID as reference, so a job that is retried after a crash cannot charge or settle twice.
+// A returned error means the processing could not be completed and the job should be retried.
+func (s *paymentService) ExecutePayment(ctx context.Context, paymentID uint) error {
+	payment, err := s.paymentRepo.GetByID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status != models.StatusPending {
+		return nil
+	}
+
+	auth, err := s.processor.Authorize(ctx, processor.ChargeRequest{
+		Reference: payment.TransactionID,
+		UserID:    payment.UserID,
+		Amount:    payment.ChargedAmount(),
+		Currency:  payment.ChargedCurrency(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if !auth.Approved() {
+		_, err := s.settlePayment(paymentID, models.StatusFailed, auth.DeclineReason)
+		return err
+	}
+
+	// the payment is claimed under its row lock before the charge is captured, a payment cancelled or failed while
+	// it was authorized is voided instead, and a payment being captured can no longer be cancelled
+	claimed, status, err := s.claimCapture(paymentID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		// a payment the processor's webhook completed in the meantime keeps its charge
+		if status == models.StatusFailed || status == models.StatusCancelled {
+			return s.processor.Void(ctx, auth)
+		}
+		return nil
+	}
+
+	if err := s.processor.Capture(ctx, auth); err != nil {
+		return err
+	}
+
+	settled, err := s.settlePayment(paymentID, models.StatusCompleted, "")
+	if err != nil {
+		return err
+	}
 
-	// Simulate payment success/failure (90% success rate)
-	if rand.Float64() < 0.9 {
-		payment.Status = models.StatusCompleted
-	} else {
-		payment.Status = models.StatusFailed
+	// the payment was failed in the meantime (e.g. by the recovery sweep), release the charge
+	if settled.Status != models.StatusCompleted {
+		return s.processor.Void(ctx, auth)
 	}
 
+	s.logger.Info("Payment Processing Done")
+	return nil
+}
+
+// claimCapture marks the pending payment as capturing under its row lock, it returns false and the status the
+// payment is in if it is no longer pending, e.g. it was cancelled while the processor authorized it.
+func (s *paymentService) claimCapture(paymentID uint) (bool, models.PaymentStatus, error) {
+	claimed := false
+	var status models.PaymentStatus
 	if err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Update payment status
-		if err := s.paymentRepo.Update(tx, payment); err != nil {
+		payment, err := s.paymentRepo.GetForUpdate(tx, paymentID)
+		if err != nil {
 			return err
 		}
-
-		if payment.Status != models.StatusCompleted {
+		status = payment.Status
+		if payment.Status != models.StatusPending {
 			return nil
 		}
 
-		// Update wallet balance if completed
-		wallet, err := s.walletRepo.GetForUpdate(tx, payment.UserID)
+		claimed = true
+		return s.paymentRepo.MarkCapturing(tx, payment)
+	}); err != nil {
+		return false, "", err
+	}
+	return claimed, status, nil
+}
+
+// FailPayment marks a pending payment failed with the given reason and releases its hold.
+// It is called when processing gave up, so that the payment does not stay pending forever. A payment that is being
+// captured may already be charged at the processor, it fails with models.ErrPaymentCapturing and is left to a retry
+// of the capture or to the processor's webhook.
+func (s *paymentService) FailPayment(paymentID uint, reason string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		payment, err := s.paymentRepo.GetForUpdate(tx, paymentID)
 		if err != nil {
 			return err
 		}
+		if payment.Status == models.StatusPending && payment.Capturing {
+			return models.ErrPaymentCapturing
+		}
 
-		wallet.Credit(payment.Amount)
-		if err := s.walletRepo.UpdateBalance(tx, wallet); err != nil {
+		_, err = s.settlePaymentTx(tx, paymentID, models.StatusFailed, reason)
+		return err
+	})
+}
+
+// CancelPayment cancels the pending payment with the transaction ID and releases the amount held in the wallet.
+// It settles the payment through the same row-locked transaction as the processor, so a payment is either cancelled
+// or completed, never both: a worker that claims the payment for capture after it was cancelled finds it no longer
+// pending and voids the authorization, and a payment the worker already claimed for capture cannot be cancelled.
+// Cancelling a cancelled payment returns it unchanged, a payment that is being captured or already reached another
+// status fails with ErrPaymentNotCancellable.
+func (s *paymentService) CancelPayment(txId string) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetByTransactionID(txId)
+	if err != nil {
+		return nil, err
+	}
+
+	settled, err := s.settlePayment(payment.ID, models.StatusCancelled, "cancelled by client")
+	if err != nil {
+		return nil, err
+	}
+	if settled.Status != models.StatusCancelled {
+		return nil, ErrPaymentNotCancellable
+	}
+	return settled, nil
+}
+
+// ApplyProcessorEvent applies an event the processor reported through the webhook, the pending payment with the
+// event's reference as transaction ID is settled as completed or failed.
+// The event is recorded in the same transaction that settles the payment, so a redelivered event is applied once;
+// it returns false for an event that was already applied. A payment that already reached a terminal status is
+// returned unchanged, the event is still recorded.
+func (s *paymentService) ApplyProcessorEvent(event *models.ProcessorEventRequest) (*models.Payment, bool, error) {
+	payment, err := s.paymentRepo.GetByTransactionID(event.Reference)
+	if err != nil {
+		return nil, false, err
+	}
+
+	status, reason := models.StatusCompleted, ""
+	if event.Type == models.EventChargeFailed {
+		status, reason = models.StatusFailed, event.DeclineReason
+	}
+
+	var settled *models.Payment
+	applied := false
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		created, err := s.eventRepo.CreateIfAbsent(tx, &models.ProcessorEvent{
+			EventID:       event.EventID,
+			Type:          event.Type,
+			TransactionID: event.Reference,
+			PaymentID:     payment.ID,
+			Reason:        reason,
+		})
+		if err != nil {
 			return err
 		}
+		if !created {
+			settled = payment
+			return nil
+		}
+
+		applied = true
+		settled, err = s.settlePaymentTx(tx, payment.ID, status, reason)
+		return err
+	}); err != nil {
+		s.logger.Error(err, "Failed to apply processor event")
+		return nil, false, err
+	}
+
+	if applied && settled.Status != status {
+		s.logger.Info(fmt.Sprintf("Processor event %s reports %s for payment %s, which is already %s", event.EventID, event.Type, settled.TransactionID, settled.Status))
+	}
+	return settled, applied, nil
+}
 
+// settlePayment moves a pending payment to completed, failed or cancelled within a database transaction,
+// see settlePaymentTx.
+func (s *paymentService) settlePayment(paymentID uint, status models.PaymentStatus, reason string) (*models.Payment, error) {
+	var settled *models.Payment
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		settled, err = s.settlePaymentTx(tx, paymentID, status, reason)
 		return err
 	}); err != nil {
-		s.logger.Error(err, "Failed to simulate payment processing")
+		s.logger.Error(err, "Failed to settle payment")
+		return nil, err
+	}
+
+	return settled, nil
+}
+
+// settlePaymentTx moves a pending payment to completed, failed or cancelled in tx. The amount held in the wallet is
+// captured if the payment is completed, together with the ledger posting that records the debit, and released otherwise.
+// For a converted payment that is the amount in the wallet currency, see Payment.ChargedAmount.
+// A payment created before holds existed is debited directly, and failed instead if the wallet can no longer cover it.
+// The payment event is written to the outbox in the same transaction, so it is published if and only if the
+// status change is committed.
+// The payment row is locked and re-checked inside the transaction, so that concurrent settlements of
+// the same payment apply only once. It returns the payment as it is after the transaction,
+// a payment that was no longer pending, or that is being captured and would be cancelled, is returned unchanged.
+func (s *paymentService) settlePaymentTx(tx *gorm.DB, paymentID uint, status models.PaymentStatus, reason string) (*models.Payment, error) {
+	payment, err := s.paymentRepo.GetForUpdate(tx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != models.StatusPending {
+		return payment, nil
+	}
+	// the charge of a payment being captured is taken at the processor, it completes or fails but is not cancelled
+	if status == models.StatusCancelled && payment.Capturing {
+		return payment, nil
+	}
+
+	wallet, err := s.walletRepo.GetForUpdate(tx, payment.UserID, payment.ChargedCurrency())
+	if err != nil {
+		return nil, err
+	}
+
+	charged := payment.ChargedAmount()
+	debited := false
+	switch {
+	case payment.FundsHeld && status == models.StatusCompleted:
+		if err := wallet.Capture(charged); err != nil {
+			return nil, err
+		}
+		debited = true
+	case payment.FundsHeld:
+		if err := wallet.Release(charged); err != nil {
+			return nil, err
+		}
+	case status == models.StatusCompleted:
+		// a payment created before holds existed, a payment the wallet can no longer cover is failed instead
+		if err := wallet.Debit(charged); err != nil {
+			if !errors.Is(err, models.ErrInsufficientFunds) {
+				return nil, err
+			}
+			status, reason = models.StatusFailed, err.Error()
+		} else {
+			debited = true
+		}
+	}
+
+	payment.FailureReason = reason
+	if err := s.paymentRepo.Transition(tx, payment, status, reason); err != nil {
+		return nil, err
 	}
 
-	s.logger.Info("[Simulate] Payment Processing Done")
+	if err := s.walletRepo.UpdateBalance(tx, wallet); err != nil {
+		return nil, err
+	}
+
+	if debited {
+		posting := walletPosting(fmt.Sprintf("payment:%d", payment.ID), wallet, models.Debit, charged,
+			models.AccountProcessorSettlement, "payment "+payment.TransactionID, &payment.ID)
+		if err := s.ledgerRepo.Post(tx, posting); err != nil {
+			return nil, err
+		}
+	}
+
+	event, err := newPaymentOutboxEvent(payment)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.outboxRepo.Create(tx, event); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// newPaymentOutboxEvent builds the event of a payment that reached a terminal status.
+// A payment reaches a terminal status once, so the event ID derived from it is stable and serves as dedup ID.
+func newPaymentOutboxEvent(payment *models.Payment) (*models.OutboxEvent, error) {
+	eventType := models.EventPaymentCompleted
+	switch payment.Status {
+	case models.StatusFailed:
+		eventType = models.EventPaymentFailed
+	case models.StatusCancelled:
+		eventType = models.EventPaymentCancelled
+	}
+	return newOutboxEvent(fmt.Sprintf("evt_payment_%d_%s", payment.ID, payment.Status), eventType, payment)
+}
+
+// newOutboxEvent builds an outbox event of the given type carrying the payment as it is in the transaction,
+// eventID must be stable for the change it reports since consumers dedup on it.
+func newOutboxEvent(eventID string, eventType models.PaymentEventType, payment *models.Payment) (*models.OutboxEvent, error) {
+	now := time.Now()
+	event := models.PaymentEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: now,
+		Data:      payment,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OutboxEvent{
+		EventID:       event.ID,
+		AggregateType: "payment",
+		AggregateID:   payment.ID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.OutboxPending,
+		NextAttemptAt: now,
+	}, nil
 }
 
 func (s *paymentService) getByTransactionIdAndUserId(payment *models.PaymentRequest) (*models.Payment, error) {
@@ -149,6 +485,11 @@ func (s *paymentService) getByTransactionIdAndUserId(payment *models.PaymentRequ
 		return nil, fmt.Errorf("user id not match [%s]", payment.UserID)
 	}
 
+	// payments created before request hashes were stored have no hash to compare against
+	if existing.RequestHash != "" && existing.RequestHash != payment.Hash() {
+		return nil, ErrIdempotencyKeyConflict
+	}
+
 	return existing, nil
 }
 
@@ -164,6 +505,44 @@ func (s *paymentService) GetPaymentByTransactionID(txId string) (*models.Payment
 	return s.paymentRepo.GetByTransactionID(txId)
 }
 
-func (s *paymentService) GetAll() ([]*models.Payment, error) {
-	return s.paymentRepo.GetAll()
+func (s *paymentService) GetPaymentHistory(txId string) ([]*models.PaymentStatusHistory, error) {
+	payment, err := s.paymentRepo.GetByTransactionID(txId)
+	if err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.ListHistory(payment.ID)
+}
+
+// ListPayments returns a page of the payments matching the filter with its metadata, the cursor of the page must be
+// the next cursor of a previous page, it fails with models.ErrInvalidCursor otherwise.
+func (s *paymentService) ListPayments(filter *models.PaymentFilter, page *models.PageQuery) ([]*models.Payment, *models.Page, error) {
+	after, err := models.DecodeCursor(page.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payments, err := s.paymentRepo.List(filter, page, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	payments, meta := paginate(payments, page)
+	return payments, meta, nil
+}
+
+// ExportPayments calls fn for every payment matching the filter, oldest first, as they are read from the database.
+// The export stops when ctx is cancelled, e.g. by a client that went away, or when fn fails.
+func (s *paymentService) ExportPayments(ctx context.Context, filter *models.PaymentFilter, fn func(payment *models.Payment) error) error {
+	return s.paymentRepo.Stream(ctx, filter, fn)
+}
+
+// paginate trims the extra item a repository list returns beyond the page size, and builds the page metadata
+// with the cursor of the last item of the page if there is a next one.
+func paginate[T interface{ Cursor() models.Cursor }](items []T, page *models.PageQuery) ([]T, *models.Page) {
+	meta := &models.Page{Limit: page.Size()}
+	if len(items) > page.Size() {
+		items = items[:page.Size()]
+		meta.HasMore = true
+		meta.NextCursor = items[len(items)-1].Cursor().Encode()
+	}
+	return items, meta
 }
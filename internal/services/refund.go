@@ -0,0 +1,159 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+type RefundService interface {
+	CreateRefund(transactionID string, req *models.RefundRequest) (*models.Refund, bool, error)
+	GetRefunds(transactionID string) ([]*models.Refund, error)
+}
+
+type refundService struct {
+	logger      logger.Logger
+	db          *gorm.DB
+	refundRepo  repositories.RefundRepository
+	paymentRepo repositories.PaymentRepository
+	walletRepo  repositories.WalletRepository
+	outboxRepo  repositories.OutboxEventRepository
+	ledgerRepo  repositories.LedgerRepository
+}
+
+func NewRefundService(
+	db *gorm.DB,
+	refundRepo repositories.RefundRepository,
+	paymentRepo repositories.PaymentRepository,
+	walletRepo repositories.WalletRepository,
+	outboxRepo repositories.OutboxEventRepository,
+	ledgerRepo repositories.LedgerRepository,
+) RefundService {
+	return &refundService{
+		logger:      logger.Logger{},
+		db:          db,
+		refundRepo:  refundRepo,
+		paymentRepo: paymentRepo,
+		walletRepo:  walletRepo,
+		outboxRepo:  outboxRepo,
+		ledgerRepo:  ledgerRepo,
+	}
+}
+
+// CreateRefund refunds the amount of the request from the payment with the transaction ID back to the user's wallet.
+// A refund ID already used with the same payment and payload returns the existing refund and false,
+// with a different payment or payload it fails with ErrRefundIDConflict.
+// It fails with ErrPaymentNotRefundable unless the payment is completed or partially refunded,
+// with ErrRefundExceedsPayment if the refunds would add up to more than the payment,
+// and with ErrRefundPrecision if the amount is finer than the minor unit of the payment currency.
+// The payment row is locked for the whole transaction, so concurrent refunds of a payment cannot exceed the cap.
+// A converted payment credits the wallet its share at the payment's rate, see models.Payment.ChargedRefund.
+func (s *refundService) CreateRefund(transactionID string, req *models.RefundRequest) (*models.Refund, bool, error) {
+	payment, err := s.paymentRepo.GetByTransactionID(transactionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if !currency.HasValidPrecision(req.Amount, currency.OrDefault(payment.Currency)) {
+		return nil, false, ErrRefundPrecision
+	}
+
+	hash := req.Hash(transactionID)
+	var refund *models.Refund
+	created := false
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		payment, err = s.paymentRepo.GetForUpdate(tx, payment.ID)
+		if err != nil {
+			return err
+		}
+
+		existing, err := s.refundRepo.GetByRefundID(tx, req.RefundID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if existing != nil {
+			if existing.PaymentID != payment.ID || existing.RequestHash != hash {
+				return ErrRefundIDConflict
+			}
+			refund = existing
+			return nil
+		}
+
+		if payment.Status != models.StatusCompleted && payment.Status != models.StatusPartiallyRefunded {
+			return ErrPaymentNotRefundable
+		}
+		if req.Amount.GreaterThan(payment.Refundable()) {
+			return ErrRefundExceedsPayment
+		}
+
+		wallet, err := s.walletRepo.GetForUpdate(tx, payment.UserID, payment.ChargedCurrency())
+		if err != nil {
+			return err
+		}
+		credit := payment.ChargedRefund(req.Amount)
+		if err := wallet.Credit(credit); err != nil {
+			return err
+		}
+		if err := s.walletRepo.UpdateBalance(tx, wallet); err != nil {
+			return err
+		}
+
+		refund = &models.Refund{
+			RefundID:       req.RefundID,
+			PaymentID:      payment.ID,
+			TransactionID:  payment.TransactionID,
+			Amount:         req.Amount,
+			WalletCurrency: payment.WalletCurrency,
+			WalletAmount:   credit,
+			Reason:         req.Reason,
+			RequestHash:    hash,
+		}
+		if err := s.refundRepo.Create(tx, refund); err != nil {
+			return err
+		}
+
+		posting := walletPosting(fmt.Sprintf("refund:%d", refund.ID), wallet, models.Credit, refund.WalletAmount,
+			models.AccountProcessorSettlement, "refund "+refund.RefundID+" of payment "+payment.TransactionID, &payment.ID)
+		if err := s.ledgerRepo.Post(tx, posting); err != nil {
+			return err
+		}
+
+		payment.RefundedAmount = payment.RefundedAmount.Add(refund.Amount)
+		status := models.StatusPartiallyRefunded
+		if payment.RefundedAmount.Equal(payment.Amount) {
+			status = models.StatusRefunded
+		}
+		if err := s.paymentRepo.Transition(tx, payment, status, "refund "+refund.RefundID); err != nil {
+			return err
+		}
+
+		event, err := newOutboxEvent(fmt.Sprintf("evt_refund_%d", refund.ID), models.EventPaymentRefunded, payment)
+		if err != nil {
+			return err
+		}
+		if err := s.outboxRepo.Create(tx, event); err != nil {
+			return err
+		}
+
+		created = true
+		return nil
+	}); err != nil {
+		s.logger.Error(err, "Failed to refund payment")
+		return nil, false, err
+	}
+
+	return refund, created, nil
+}
+
+func (s *refundService) GetRefunds(transactionID string) ([]*models.Refund, error) {
+	payment, err := s.paymentRepo.GetByTransactionID(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.refundRepo.ListByPaymentID(payment.ID)
+}
@@ -0,0 +1,193 @@
+package services_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/processor"
+	"payment-service/internal/processor/gatewaystub"
+	"payment-service/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gatedProcessor approves every payment once the gate is opened, and counts the captured and voided authorizations.
+type gatedProcessor struct {
+	gate     chan struct{}
+	captured atomic.Int32
+	voided   atomic.Int32
+}
+
+func (p *gatedProcessor) Authorize(ctx context.Context, req processor.ChargeRequest) (*processor.Authorization, error) {
+	select {
+	case <-p.gate:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &processor.Authorization{ID: "auth_" + req.Reference, Reference: req.Reference, Status: processor.AuthorizationApproved}, nil
+}
+
+func (p *gatedProcessor) Capture(context.Context, *processor.Authorization) error {
+	p.captured.Add(1)
+	return nil
+}
+
+func (p *gatedProcessor) Void(context.Context, *processor.Authorization) error {
+	p.voided.Add(1)
+	return nil
+}
+
+func TestCancelPendingPaymentReleasesFunds(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+
+	payment, err := tc.PaymentService.CancelPayment("tx123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, payment.Status)
+	assertWalletDebited(t, tc, false)
+
+	t.Run("Cancelling again returns the cancelled payment", func(t *testing.T) {
+		payment, err := tc.PaymentService.CancelPayment("tx123")
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusCancelled, payment.Status)
+	})
+
+	t.Run("Late processor result does not debit the wallet", func(t *testing.T) {
+		event := &models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"}
+		payment, _, err := tc.PaymentService.ApplyProcessorEvent(event)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusCancelled, payment.Status)
+		assertWalletDebited(t, tc, false)
+	})
+}
+
+func TestCancelSettledPaymentIsRejected(t *testing.T) {
+	tc := Initiate(t)
+	payAndWait(t, tc)
+
+	_, err := tc.PaymentService.CancelPayment("tx123")
+	assert.ErrorIs(t, err, services.ErrPaymentNotCancellable)
+	assertWalletDebited(t, tc, true)
+}
+
+func TestCancelWhileProcessingVoidsCharge(t *testing.T) {
+	p := &gatedProcessor{gate: make(chan struct{})}
+	tc := InitiateWithProcessor(t, p)
+	createPendingPayment(t, tc, "tx123")
+
+	// the worker is waiting for the processor when the payment is cancelled
+	time.Sleep(200 * time.Millisecond)
+	_, err := tc.PaymentService.CancelPayment("tx123")
+	require.NoError(t, err)
+	close(p.gate)
+
+	require.Eventually(t, func() bool { return p.voided.Load() == 1 }, 5*time.Second, 50*time.Millisecond,
+		"the approved charge of a cancelled payment should be voided")
+	assert.Zero(t, p.captured.Load(), "the charge of a cancelled payment should never be captured")
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, payment.Status)
+	assertWalletDebited(t, tc, false)
+}
+
+func TestCancelDuringGatewayAuthorizationVoidsCharge(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	// the charge stays pending at the gateway long enough to cancel the payment while it is authorized
+	stub.PendingPolls = 20
+	stub.Script(gatewaystub.OpCreateCharge, gatewaystub.Pending)
+	createPendingPayment(t, tc, "tx123")
+
+	require.Eventually(t, func() bool { return stub.Calls(gatewaystub.OpGetCharge) > 0 }, 5*time.Second, 10*time.Millisecond)
+	_, err := tc.PaymentService.CancelPayment("tx123")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		charge, ok := stub.Charge("tx123")
+		return ok && charge.Status == processor.ChargeVoided
+	}, 5*time.Second, 50*time.Millisecond, "the charge of a cancelled payment should be voided, not captured")
+	assert.Zero(t, stub.Calls(gatewaystub.OpCapture))
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, payment.Status)
+	assertWalletDebited(t, tc, false)
+}
+
+func TestCancelWhileCapturingIsRejected(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	// the first capture attempt hangs until the client gives up, the payment is claimed for capture meanwhile
+	stub.Script(gatewaystub.OpCapture, gatewaystub.Timeout, gatewaystub.Timeout)
+	createPendingPayment(t, tc, "tx123")
+
+	require.Eventually(t, func() bool { return stub.Calls(gatewaystub.OpCapture) > 0 }, 5*time.Second, 10*time.Millisecond)
+	_, err := tc.PaymentService.CancelPayment("tx123")
+	assert.ErrorIs(t, err, services.ErrPaymentNotCancellable)
+
+	// the retried job captures the charge and completes the payment
+	require.Eventually(t, func() bool {
+		payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+		return err == nil && payment.Status == models.StatusCompleted
+	}, 5*time.Second, 50*time.Millisecond)
+
+	charge, ok := stub.Charge("tx123")
+	require.True(t, ok)
+	assert.Equal(t, processor.ChargeCaptured, charge.Status)
+	assertWalletDebited(t, tc, true)
+}
+
+func TestCapturingPaymentIsNotFailed(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	payment := createPendingPayment(t, tc, "tx123")
+	require.NoError(t, tc.PaymentRepo.MarkCapturing(testDB, payment))
+
+	// giving up on the job must not release the hold of a payment the processor may have charged
+	err := tc.PaymentService.FailPayment(payment.ID, "processing gave up")
+	assert.ErrorIs(t, err, models.ErrPaymentCapturing)
+	payment, err = tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPending, payment.Status)
+
+	// the processor confirms the capture
+	event := &models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"}
+	payment, _, err = tc.PaymentService.ApplyProcessorEvent(event)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+	assertWalletDebited(t, tc, true)
+}
+
+func TestConcurrentCancelAndSettlement(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+
+	var wg sync.WaitGroup
+	var cancelErr, eventErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, cancelErr = tc.PaymentService.CancelPayment("tx123")
+	}()
+	go func() {
+		defer wg.Done()
+		event := &models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"}
+		_, _, eventErr = tc.PaymentService.ApplyProcessorEvent(event)
+	}()
+	wg.Wait()
+	require.NoError(t, eventErr)
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	if cancelErr == nil {
+		assert.Equal(t, models.StatusCancelled, payment.Status)
+		assertWalletDebited(t, tc, false)
+	} else {
+		assert.ErrorIs(t, cancelErr, services.ErrPaymentNotCancellable)
+		assert.Equal(t, models.StatusCompleted, payment.Status)
+		assertWalletDebited(t, tc, true)
+	}
+}
@@ -0,0 +1,162 @@
+package services_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openWallet(t *testing.T, tc *TestContext, user *models.User, code string, balance int64) *models.Wallet {
+	wallet, err := tc.UserService.CreateWallet(user.UserID, code)
+	require.NoError(t, err)
+	if balance > 0 {
+		req := &models.DepositRequest{DepositID: "open-" + user.UserID + "-" + code, Amount: decimal.NewFromInt(balance), Currency: code}
+		_, _, err = tc.DepositService.Deposit(tc.Ctx, user.UserID, req)
+		require.NoError(t, err)
+	}
+	return wallet
+}
+
+func TestOneWalletPerCurrency(t *testing.T) {
+	tc := Initiate(t)
+	openWallet(t, tc, tc.User, "EUR", 0)
+
+	_, err := tc.UserService.CreateWallet(tc.User.UserID, "EUR")
+	assert.ErrorIs(t, err, services.ErrWalletExists)
+	_, err = tc.UserService.CreateWallet(tc.User.UserID, currency.Default)
+	assert.ErrorIs(t, err, services.ErrWalletExists)
+
+	wallets, err := tc.UserService.GetWallets(tc.User.UserID)
+	require.NoError(t, err)
+	assert.Len(t, wallets, 2)
+
+	user, err := tc.UserService.GetUserDetail(tc.User.UserID)
+	require.NoError(t, err)
+	require.NotNil(t, user.Wallet)
+	assert.Equal(t, currency.Default, user.Wallet.Currency)
+}
+
+func TestConcurrentWalletOpeningsCreateOneWallet(t *testing.T) {
+	tc := Initiate(t)
+
+	const attempts = 5
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = tc.UserService.CreateWallet(tc.User.UserID, "EUR")
+		}(i)
+	}
+	wg.Wait()
+
+	opened := 0
+	for _, err := range errs {
+		if err == nil {
+			opened++
+			continue
+		}
+		assert.ErrorIs(t, err, services.ErrWalletExists)
+	}
+	assert.Equal(t, 1, opened, "exactly one opening should succeed")
+
+	wallets, err := tc.UserService.GetWallets(tc.User.UserID)
+	require.NoError(t, err)
+	assert.Len(t, wallets, 2)
+}
+
+func TestNewWalletsGetTheOverdraftLimit(t *testing.T) {
+	tc := Initiate(t)
+	assert.True(t, tc.Wallet.OverdraftLimit.IsZero(), "no overdraft by default")
+
+	overdraftLimit := decimal.NewFromInt(50)
+	userService := services.NewUserService(testDB, tc.UserRepo, tc.WalletRepo, tc.LedgerRepo, tc.PaymentRepo, overdraftLimit)
+
+	user, err := userService.Generate()
+	require.NoError(t, err)
+	assert.True(t, user.Wallet.OverdraftLimit.Equal(overdraftLimit))
+
+	wallet, err := userService.CreateWallet(user.UserID, "EUR")
+	require.NoError(t, err)
+	assert.True(t, wallet.OverdraftLimit.Equal(overdraftLimit))
+
+	// the empty wallet can be debited down to the limit
+	assert.NoError(t, wallet.CanDebit(overdraftLimit))
+	assert.ErrorIs(t, wallet.CanDebit(overdraftLimit.Add(decimal.NewFromInt(1))), models.ErrInsufficientFunds)
+}
+
+func TestPaymentDebitsWalletInItsCurrency(t *testing.T) {
+	tc := Initiate(t)
+	openWallet(t, tc, tc.User, "EUR", 500)
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        decimal.NewFromInt(200),
+		Currency:      "EUR",
+		TransactionID: "tx_eur",
+	})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		payment, err := tc.PaymentService.GetPaymentByTransactionID("tx_eur")
+		return err == nil && payment.Status == models.StatusCompleted
+	}, 5*tc.EstimatedProcessTime, 100*time.Millisecond)
+
+	eur, err := tc.WalletRepo.GetByUserId(tc.User.UserID, "EUR")
+	require.NoError(t, err)
+	assert.True(t, eur.Balance.Equal(decimal.NewFromInt(300)))
+	assertWalletDebited(t, tc, false)
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, "EUR")
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled)
+}
+
+func TestPaymentWithoutWalletInCurrencyIsRejected(t *testing.T) {
+	tc := Initiate(t)
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        decimal.NewFromInt(100),
+		Currency:      "GBP",
+		TransactionID: "tx_gbp",
+	})
+	assert.ErrorIs(t, err, services.ErrCurrencyMismatch)
+
+	_, err = tc.PaymentService.GetPaymentByTransactionID("tx_gbp")
+	assert.Error(t, err, "a rejected payment should not be recorded")
+}
+
+func TestTransferRequiresWalletsInCurrency(t *testing.T) {
+	tc := Initiate(t)
+	recipient, err := tc.UserService.Generate()
+	require.NoError(t, err)
+	openWallet(t, tc, tc.User, "EUR", 500)
+
+	req := &models.TransferRequest{
+		TransferID: "tr_eur",
+		FromUserID: tc.User.UserID,
+		ToUserID:   recipient.UserID,
+		Amount:     decimal.NewFromInt(100),
+		Currency:   "EUR",
+	}
+	_, _, err = tc.TransferService.Transfer(tc.Ctx, req)
+	assert.ErrorIs(t, err, services.ErrCurrencyMismatch)
+
+	openWallet(t, tc, recipient, "EUR", 0)
+	_, _, err = tc.TransferService.Transfer(tc.Ctx, req)
+	require.NoError(t, err)
+
+	received, err := tc.WalletRepo.GetByUserId(recipient.UserID, "EUR")
+	require.NoError(t, err)
+	assert.True(t, received.Balance.Equal(decimal.NewFromInt(100)))
+	assert.True(t, balanceOf(t, tc, recipient).Equal(recipient.Wallet.Balance), "the wallet in the default currency is untouched")
+}
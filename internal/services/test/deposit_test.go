@@ -0,0 +1,107 @@
+package services_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func depositRequest(depositID string, amount int64) *models.DepositRequest {
+	return &models.DepositRequest{DepositID: depositID, Amount: decimal.NewFromInt(amount)}
+}
+
+func assertWalletBalance(t *testing.T, tc *TestContext, expected decimal.Decimal) {
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, wallet.Balance.Equal(expected), "wallet balance %s, expected %s", wallet.Balance, expected)
+}
+
+func TestDepositCreditsWallet(t *testing.T) {
+	tc := Initiate(t)
+
+	deposit, created, err := tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, depositRequest("dep1", 500))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, models.DepositCompleted, deposit.Status)
+	assertWalletBalance(t, tc, tc.Wallet.Balance.Add(decimal.NewFromInt(500)))
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled, "stored balance %s should match ledger balance %s", ledger.StoredBalance, ledger.LedgerBalance)
+
+	deposits, err := tc.DepositService.GetDeposits(tc.User.UserID)
+	require.NoError(t, err)
+	assert.Len(t, deposits, 1)
+}
+
+func TestDepositIsIdempotent(t *testing.T) {
+	tc := Initiate(t)
+
+	first, _, err := tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, depositRequest("dep1", 500))
+	require.NoError(t, err)
+
+	replay, created, err := tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, depositRequest("dep1", 500))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, replay.ID)
+	assertWalletBalance(t, tc, tc.Wallet.Balance.Add(decimal.NewFromInt(500)))
+
+	_, _, err = tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, depositRequest("dep1", 600))
+	assert.ErrorIs(t, err, services.ErrDepositIDConflict)
+}
+
+func TestConcurrentDuplicateDepositsCreditOnce(t *testing.T) {
+	tc := Initiate(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, depositRequest("dep1", 500))
+			if err != nil {
+				assert.ErrorIs(t, err, services.ErrDepositInProgress)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assertWalletBalance(t, tc, tc.Wallet.Balance.Add(decimal.NewFromInt(500)))
+}
+
+func TestPendingDepositIsCompletedOnRetry(t *testing.T) {
+	tc := Initiate(t)
+
+	// a deposit that was recorded but not credited, e.g. the process died in between
+	req := depositRequest("dep1", 500)
+	pending := &models.Deposit{
+		DepositID:   req.DepositID,
+		UserID:      tc.User.UserID,
+		Amount:      req.Amount,
+		Status:      models.DepositPending,
+		RequestHash: req.Hash(tc.User.UserID),
+	}
+	require.NoError(t, testDB.Create(pending).Error)
+
+	deposit, created, err := tc.DepositService.Deposit(tc.Ctx, tc.User.UserID, req)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, models.DepositCompleted, deposit.Status)
+	assertWalletBalance(t, tc, tc.Wallet.Balance.Add(decimal.NewFromInt(500)))
+}
+
+func TestDepositUnknownUser(t *testing.T) {
+	tc := Initiate(t)
+
+	_, _, err := tc.DepositService.Deposit(tc.Ctx, fmt.Sprintf("missing-%d", tc.User.ID), depositRequest("dep1", 500))
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,133 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/fx"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payEURFromUSD pays 100 EUR from the user's wallet in the default currency and waits for it to complete
+func payEURFromUSD(t *testing.T, tc *TestContext, transactionID string, quoteID string) *models.Payment {
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:         tc.User.UserID,
+		Amount:         decimal.NewFromInt(100),
+		Currency:       "EUR",
+		TransactionID:  transactionID,
+		WalletCurrency: currency.Default,
+		QuoteID:        quoteID,
+	})
+	require.NoError(t, err)
+
+	var payment *models.Payment
+	require.Eventually(t, func() bool {
+		payment, err = tc.PaymentService.GetPaymentByTransactionID(transactionID)
+		return err == nil && payment.Status == models.StatusCompleted
+	}, 5*tc.EstimatedProcessTime, 100*time.Millisecond)
+	return payment
+}
+
+func TestCrossCurrencyPaymentIsConverted(t *testing.T) {
+	tc := Initiate(t)
+
+	payment := payEURFromUSD(t, tc, "tx_fx", "")
+
+	// 100 EUR at 1.08 with the default spread of 0.5%
+	assert.Equal(t, currency.Default, payment.WalletCurrency)
+	assert.True(t, payment.FxRate.Equal(decimal.RequireFromString("1.08")))
+	assert.True(t, payment.FxSpread.Equal(fx.DefaultOptions().Spread))
+	assert.True(t, payment.WalletAmount.Equal(decimal.RequireFromString("108.54")), "wallet amount %s", payment.WalletAmount)
+	assertWalletBalance(t, tc, tc.Wallet.Balance.Sub(payment.WalletAmount))
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled)
+}
+
+func TestCrossCurrencyPaymentIsChargedInWalletCurrency(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+
+	payment := payEURFromUSD(t, tc, "tx_fx", "")
+
+	charge, ok := stub.Charge(payment.TransactionID)
+	require.True(t, ok)
+	assert.Equal(t, currency.Default, charge.Currency)
+	assert.Equal(t, payment.WalletAmount.String(), charge.Amount)
+}
+
+func TestPaymentHonorsQuote(t *testing.T) {
+	tc := Initiate(t)
+
+	quote, err := tc.FxService.CreateQuote(tc.Ctx, &models.FxQuoteRequest{Currency: "EUR", WalletCurrency: currency.Default})
+	require.NoError(t, err)
+	assert.True(t, quote.ExpiresAt.After(time.Now()))
+
+	payment := payEURFromUSD(t, tc, "tx_quote", quote.QuoteID)
+	assert.Equal(t, quote.QuoteID, payment.FxQuoteID)
+	assert.True(t, payment.FxRate.Equal(quote.Rate))
+	assert.True(t, payment.FxSpread.Equal(quote.Spread))
+	assert.True(t, payment.WalletAmount.Equal(decimal.RequireFromString("108.54")))
+}
+
+func TestPaymentRejectsUnusableQuote(t *testing.T) {
+	tc := Initiate(t)
+
+	quote, err := tc.FxService.CreateQuote(tc.Ctx, &models.FxQuoteRequest{Currency: "EUR", WalletCurrency: currency.Default})
+	require.NoError(t, err)
+
+	pay := func(transactionID string, code string, quoteID string) error {
+		_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+			UserID:        tc.User.UserID,
+			Amount:        decimal.NewFromInt(100),
+			Currency:      code,
+			TransactionID: transactionID,
+			QuoteID:       quoteID,
+		})
+		return err
+	}
+
+	assert.ErrorIs(t, pay("tx_unknown", "EUR", "quote_unknown"), services.ErrQuoteInvalid)
+	assert.ErrorIs(t, pay("tx_mismatch", "JPY", quote.QuoteID), services.ErrQuoteInvalid)
+
+	require.NoError(t, testDB.Model(&models.FxQuote{}).Where("quote_id = ?", quote.QuoteID).
+		Update("expires_at", time.Now().Add(-time.Second)).Error)
+	assert.ErrorIs(t, pay("tx_expired", "EUR", quote.QuoteID), services.ErrQuoteExpired)
+
+	assertWalletBalance(t, tc, tc.Wallet.Balance)
+}
+
+func TestCrossCurrencyPaymentWithoutRateIsRejected(t *testing.T) {
+	tc := Initiate(t)
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:         tc.User.UserID,
+		Amount:         decimal.NewFromInt(100),
+		Currency:       "GBP",
+		TransactionID:  "tx_gbp",
+		WalletCurrency: currency.Default,
+	})
+	assert.ErrorIs(t, err, fx.ErrRateUnavailable)
+}
+
+func TestConvertedPaymentIsRefundedAtSnapshot(t *testing.T) {
+	tc := Initiate(t)
+	payment := payEURFromUSD(t, tc, "tx_fx_refund", "")
+
+	refund, _, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf_fx1", 30))
+	require.NoError(t, err)
+	assert.True(t, refund.WalletAmount.Equal(decimal.RequireFromString("32.56")), "wallet amount %s", refund.WalletAmount)
+
+	refund, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf_fx2", 70))
+	require.NoError(t, err)
+	assert.True(t, refund.WalletAmount.Equal(decimal.RequireFromString("75.98")), "wallet amount %s", refund.WalletAmount)
+
+	// the refunds give back exactly what the payment took
+	assertWalletBalance(t, tc, tc.Wallet.Balance)
+}
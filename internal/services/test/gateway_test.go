@@ -0,0 +1,115 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/processor"
+	"payment-service/internal/processor/gatewaystub"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// InitiateWithGateway runs the payment lifecycle against the HTTP gateway adapter and a local stub gateway.
+func InitiateWithGateway(t *testing.T) (*TestContext, *gatewaystub.Stub) {
+	stub := gatewaystub.New()
+	stub.TimeoutDelay = time.Second
+	server := stub.Start()
+	t.Cleanup(server.Close)
+
+	gateway := processor.NewGatewayProcessor(processor.GatewayOptions{
+		BaseURL:        server.URL,
+		APIKey:         "test-key",
+		RequestTimeout: 100 * time.Millisecond,
+		MaxRetries:     1,
+		RetryBackoff:   time.Millisecond,
+		PollInterval:   10 * time.Millisecond,
+		PollTimeout:    time.Second,
+	})
+
+	return InitiateWithProcessor(t, gateway), stub
+}
+
+func payAndWait(t *testing.T, tc *TestContext) *models.Payment {
+	req := &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        decimal.NewFromInt(100),
+		TransactionID: "tx123",
+	}
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, req)
+	require.NoError(t, err)
+	time.Sleep(tc.EstimatedProcessTime)
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID(req.TransactionID)
+	require.NoError(t, err)
+	return payment
+}
+
+func assertWalletDebited(t *testing.T, tc *TestContext, debited bool) {
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+
+	expected := tc.Wallet.Balance
+	if debited {
+		expected = expected.Sub(decimal.NewFromInt(100))
+	}
+	assert.True(t, wallet.Balance.Equal(expected), "wallet balance %s, expected %s", wallet.Balance, expected)
+	assert.True(t, wallet.HeldBalance.IsZero(), "held balance %s should be captured or released", wallet.HeldBalance)
+}
+
+func TestGatewayApprovedAfterTransientFailures(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	stub.Script(gatewaystub.OpCreateCharge, gatewaystub.ServerError, gatewaystub.Timeout, gatewaystub.ServerError, gatewaystub.Approve)
+	stub.Script(gatewaystub.OpCapture, gatewaystub.Timeout)
+
+	payment := payAndWait(t, tc)
+
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+	charge, ok := stub.Charge(payment.TransactionID)
+	assert.True(t, ok)
+	assert.Equal(t, processor.ChargeCaptured, charge.Status, "charge should be captured exactly once")
+	assertWalletDebited(t, tc, true)
+}
+
+func TestGatewayPendingChargeIsPolled(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	stub.Script(gatewaystub.OpCreateCharge, gatewaystub.Pending)
+
+	payment := payAndWait(t, tc)
+
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+	assert.GreaterOrEqual(t, stub.Calls(gatewaystub.OpGetCharge), 1)
+	assertWalletDebited(t, tc, true)
+}
+
+func TestGatewayDeclined(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	stub.Script(gatewaystub.OpCreateCharge, gatewaystub.Decline)
+
+	payment := payAndWait(t, tc)
+
+	assert.Equal(t, models.StatusFailed, payment.Status)
+	assert.Equal(t, "card declined", payment.FailureReason)
+	assert.Zero(t, stub.Calls(gatewaystub.OpCapture))
+	assertWalletDebited(t, tc, false)
+}
+
+func TestGatewayUnavailable(t *testing.T) {
+	tc, stub := InitiateWithGateway(t)
+	stub.SetDefault(gatewaystub.ServerError)
+
+	payment := payAndWait(t, tc)
+
+	assert.Equal(t, models.StatusFailed, payment.Status, "payment should be failed once the job is dead-lettered")
+	assert.Contains(t, payment.FailureReason, "processing gave up")
+
+	job, err := tc.PaymentJobRepo.GetByPaymentID(payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobDead, job.Status)
+	assertWalletDebited(t, tc, false)
+}
@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"testing"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerOpeningBalance(t *testing.T) {
+	tc := Initiate(t)
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled)
+	assert.True(t, ledger.LedgerBalance.Equal(tc.Wallet.Balance))
+	require.Len(t, ledger.Entries, 1)
+	assert.Equal(t, models.Credit, ledger.Entries[0].Direction)
+
+	funding, err := tc.LedgerRepo.ListByAccount(models.AccountFunding)
+	require.NoError(t, err)
+	require.Len(t, funding, 1)
+	assert.Equal(t, models.Debit, funding[0].Direction)
+}
+
+func TestLedgerPostingPerPayment(t *testing.T) {
+	tc := Initiate(t)
+
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	entries, err := tc.LedgerRepo.ListByPayment(payment.ID)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "a payment should be posted as one debit and one credit")
+	assert.NoError(t, models.CheckBalanced(entries))
+	assert.Equal(t, models.WalletAccount(tc.User.UserID, currency.Default), entries[0].Account)
+	assert.Equal(t, models.Debit, entries[0].Direction)
+	assert.Equal(t, models.AccountProcessorSettlement, entries[1].Account)
+	assert.Equal(t, models.Credit, entries[1].Direction)
+	assert.True(t, entries[0].Amount.Equal(payment.Amount))
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled, "stored balance %s should match ledger balance %s", ledger.StoredBalance, ledger.LedgerBalance)
+
+	mismatches, err := tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+}
+
+func TestLedgerFailedPaymentHasNoPosting(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	payment := createPendingPayment(t, tc, "tx123")
+	require.NoError(t, tc.PaymentService.FailPayment(payment.ID, "declined"))
+
+	entries, err := tc.LedgerRepo.ListByPayment(payment.ID)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReconciliationFlagsMismatchedWallet(t *testing.T) {
+	tc := Initiate(t)
+
+	// change the balance behind the ledger's back
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	wallet.Balance = wallet.Balance.Add(decimal.NewFromInt(50))
+	require.NoError(t, tc.WalletRepo.UpdateBalance(testDB, wallet))
+
+	mismatches, err := tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, wallet.ID, mismatches[0].WalletID)
+	assert.True(t, mismatches[0].Difference.Equal(decimal.NewFromInt(50)))
+	assert.True(t, mismatches[0].LedgerBalance.Equal(tc.Wallet.Balance))
+
+	records, err := tc.LedgerService.GetReconciliations(10)
+	require.NoError(t, err)
+	assert.Len(t, records, 1, "the flagged wallet should be recorded")
+
+	// an unchanged mismatch is flagged again but not recorded again
+	mismatches, err = tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	records, err = tc.LedgerService.GetReconciliations(10)
+	require.NoError(t, err)
+	assert.Len(t, records, 1, "an unchanged mismatch should not be recorded again")
+
+	// a changed mismatch is recorded
+	wallet.Balance = wallet.Balance.Add(decimal.NewFromInt(5))
+	require.NoError(t, tc.WalletRepo.UpdateBalance(testDB, wallet))
+	_, err = tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	records, err = tc.LedgerService.GetReconciliations(10)
+	require.NoError(t, err)
+	require.Len(t, records, 2, "a changed mismatch should be recorded")
+	assert.True(t, records[0].Difference.Equal(decimal.NewFromInt(55)))
+}
+
+func TestLedgerBackfillsWalletsWithoutEntries(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	// the wallet and its payment predate the ledger
+	require.NoError(t, testDB.Exec("DELETE FROM ledger_entries").Error)
+	mismatches, err := tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+
+	backfilled, err := tc.LedgerService.BackfillWallets()
+	require.NoError(t, err)
+	assert.Equal(t, 1, backfilled)
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled, "stored balance %s should match ledger balance %s", ledger.StoredBalance, ledger.LedgerBalance)
+	require.Len(t, ledger.Entries, 2)
+	assert.Equal(t, models.Credit, ledger.Entries[0].Direction)
+	assert.True(t, ledger.Entries[0].Amount.Equal(tc.Wallet.Balance), "the opening balance is the balance before the payment")
+	assert.Equal(t, models.Debit, ledger.Entries[1].Direction)
+	assert.Equal(t, &payment.ID, ledger.Entries[1].PaymentID)
+
+	mismatches, err = tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	assert.Empty(t, mismatches)
+
+	// running it again does nothing
+	backfilled, err = tc.LedgerService.BackfillWallets()
+	require.NoError(t, err)
+	assert.Equal(t, 0, backfilled)
+	entries, err := tc.LedgerRepo.ListByPayment(payment.ID)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
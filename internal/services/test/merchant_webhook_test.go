@@ -0,0 +1,175 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/webhook"
+	"payment-service/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// merchantReceiver is a local merchant endpoint that records the events it accepted.
+type merchantReceiver struct {
+	mu     sync.Mutex
+	secret string
+	fail   bool
+	events []models.PaymentEvent
+}
+
+func (r *merchantReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	if err := webhook.Verify(r.secret, req.Header.Get(webhook.SignatureHeader), req.Header.Get(webhook.TimestampHeader), body, time.Now(), time.Minute); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var event models.PaymentEvent
+	_ = json.Unmarshal(body, &event)
+	r.events = append(r.events, event)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *merchantReceiver) received() []models.PaymentEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.PaymentEvent(nil), r.events...)
+}
+
+func (r *merchantReceiver) setFail(fail bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func startMerchant(t *testing.T, tc *TestContext) (*merchantReceiver, *models.WebhookEndpoint) {
+	recv := &merchantReceiver{}
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	endpoint, err := tc.WebhookService.RegisterEndpoint(&models.WebhookEndpointRequest{URL: server.URL})
+	require.NoError(t, err)
+	require.NotEmpty(t, endpoint.Secret)
+	recv.secret = endpoint.Secret
+
+	opts := worker.DefaultWebhookOptions()
+	opts.PollInterval = 20 * time.Millisecond
+	opts.MaxAttempts = 2
+	opts.BaseBackoff = 10 * time.Millisecond
+	dispatcher := worker.NewWebhookDispatcher(opts, tc.EndpointRepo, tc.DeliveryRepo)
+	ctx, stop := context.WithCancel(context.Background())
+	dispatcher.Start(ctx)
+
+	// the outbox relay hands the payment events over to the webhooks
+	outboxOptions := worker.DefaultOutboxOptions()
+	outboxOptions.PollInterval = 20 * time.Millisecond
+	relay := worker.NewOutboxRelay(outboxOptions, tc.OutboxRepo, tc.WebhookService)
+	relay.Start(ctx)
+
+	t.Cleanup(func() {
+		stop()
+		dispatcher.Wait()
+		relay.Wait()
+	})
+
+	return recv, endpoint
+}
+
+func TestMerchantWebhookOnCompletion(t *testing.T) {
+	tc := Initiate(t)
+	recv, endpoint := startMerchant(t, tc)
+
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	assert.Eventually(t, func() bool { return len(recv.received()) == 1 }, 2*time.Second, 20*time.Millisecond)
+	event := recv.received()[0]
+	assert.Equal(t, models.EventPaymentCompleted, event.Type)
+	assert.Equal(t, payment.TransactionID, event.Data.TransactionID)
+	assert.Equal(t, models.StatusCompleted, event.Data.Status)
+
+	deliveries, err := tc.WebhookService.GetDeliveries(endpoint.ID)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, event.ID, deliveries[0].EventID)
+	assert.Eventually(t, func() bool {
+		delivery, err := tc.WebhookService.GetDelivery(deliveries[0].ID)
+		return err == nil && delivery.Status == models.DeliverySucceeded && len(delivery.AttemptLog) == 1
+	}, time.Second, 20*time.Millisecond)
+
+	listed, err := tc.WebhookService.GetEndpoints()
+	require.NoError(t, err)
+	assert.Empty(t, listed[0].Secret, "the secret should only be returned on registration")
+}
+
+func TestMerchantWebhookRedelivery(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	recv, endpoint := startMerchant(t, tc)
+	recv.setFail(true)
+
+	createPendingPayment(t, tc, "tx123")
+	_, _, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+		EventID: "evt_1", Type: models.EventChargeFailed, Reference: "tx123", DeclineReason: "card declined",
+	})
+	require.NoError(t, err)
+
+	var deliveryID uint
+	assert.Eventually(t, func() bool {
+		deliveries, err := tc.WebhookService.GetDeliveries(endpoint.ID)
+		if err != nil || len(deliveries) != 1 {
+			return false
+		}
+		deliveryID = deliveries[0].ID
+		return deliveries[0].Status == models.DeliveryFailed
+	}, 2*time.Second, 20*time.Millisecond, "delivery should fail after the last attempt")
+
+	delivery, err := tc.WebhookService.GetDelivery(deliveryID)
+	require.NoError(t, err)
+	assert.Len(t, delivery.AttemptLog, 2)
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+
+	recv.setFail(false)
+	queued, err := tc.WebhookService.RedeliverFailed(endpoint.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), queued)
+
+	assert.Eventually(t, func() bool { return len(recv.received()) == 1 }, 2*time.Second, 20*time.Millisecond)
+	event := recv.received()[0]
+	assert.Equal(t, models.EventPaymentFailed, event.Type)
+	assert.Equal(t, "card declined", event.Data.FailureReason)
+}
+
+func TestMerchantWebhookInactiveEndpoint(t *testing.T) {
+	tc := Initiate(t)
+	recv, endpoint := startMerchant(t, tc)
+	require.NoError(t, tc.WebhookService.DeactivateEndpoint(endpoint.ID))
+
+	payment := payAndWait(t, tc)
+
+	assert.Eventually(t, func() bool {
+		event, err := tc.OutboxRepo.GetByEventID(fmt.Sprintf("evt_payment_%d_completed", payment.ID))
+		return err == nil && event.Status == models.OutboxPublished
+	}, time.Second, 20*time.Millisecond)
+
+	deliveries, err := tc.WebhookService.GetDeliveries(endpoint.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries, "an inactive endpoint should not get new events")
+	assert.Empty(t, recv.received())
+}
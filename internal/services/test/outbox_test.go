@@ -0,0 +1,118 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/outbox"
+	"payment-service/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxEventWrittenWithSettlement(t *testing.T) {
+	tc := Initiate(t)
+
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	events, err := tc.OutboxRepo.ListByAggregate("payment", payment.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "the settlement should write exactly one event")
+	assert.Equal(t, models.EventPaymentCompleted, events[0].EventType)
+	assert.Equal(t, models.OutboxPending, events[0].Status)
+
+	var event models.PaymentEvent
+	require.NoError(t, json.Unmarshal(events[0].Payload, &event))
+	assert.Equal(t, events[0].EventID, event.ID)
+	assert.Equal(t, models.StatusCompleted, event.Data.Status)
+
+	t.Run("Settling again writes no further event", func(t *testing.T) {
+		require.NoError(t, tc.PaymentService.FailPayment(payment.ID, "too late"))
+
+		events, err := tc.OutboxRepo.ListByAggregate("payment", payment.ID)
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+}
+
+func TestOutboxRelayToFileSink(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+	createPendingPayment(t, tc, "tx456")
+
+	for _, ref := range []string{"tx123", "tx456"} {
+		_, _, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+			EventID: "evt_" + ref, Type: models.EventChargeSucceeded, Reference: ref,
+		})
+		require.NoError(t, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := outbox.NewFileSink(path)
+	require.NoError(t, err)
+	defer func() { _ = sink.Close() }()
+
+	relay := worker.NewOutboxRelay(worker.DefaultOutboxOptions(), tc.OutboxRepo, sink)
+	assert.Equal(t, 2, relay.RunOnce(context.Background()))
+	assert.Equal(t, 0, relay.RunOnce(context.Background()), "published events should not be published again")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	event, err := tc.OutboxRepo.GetByEventID(fmt.Sprintf("evt_payment_%d_completed", payment.ID))
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxPublished, event.Status)
+	assert.NotNil(t, event.PublishedAt)
+	assert.Equal(t, 1, event.Attempts)
+}
+
+func TestOutboxRelayRetriesUntilSinkAccepts(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+
+	down := true
+	sink := sinkFunc(func(ctx context.Context, event *models.OutboxEvent) error {
+		if down {
+			return assert.AnError
+		}
+		return nil
+	})
+
+	opts := worker.DefaultOutboxOptions()
+	opts.BaseBackoff = time.Millisecond
+	relay := worker.NewOutboxRelay(opts, tc.OutboxRepo, sink)
+	assert.Equal(t, 1, relay.RunOnce(context.Background()))
+
+	events, err := tc.OutboxRepo.ListByAggregate("payment", payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxPending, events[0].Status, "a rejected event should stay in the outbox")
+	assert.Equal(t, assert.AnError.Error(), events[0].LastError)
+
+	down = false
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 1, relay.RunOnce(context.Background()))
+
+	events, err = tc.OutboxRepo.ListByAggregate("payment", payment.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxPublished, events[0].Status)
+	assert.Equal(t, 2, events[0].Attempts)
+}
+
+type sinkFunc func(ctx context.Context, event *models.OutboxEvent) error
+
+func (f sinkFunc) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	return f(ctx, event)
+}
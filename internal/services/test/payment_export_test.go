@@ -0,0 +1,46 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportPaymentsStreamsFilteredPayments(t *testing.T) {
+	tc := Initiate(t)
+	start := time.Now().Add(-time.Hour).UTC()
+	seeded := seedPayments(t, tc, tc.User, start,
+		models.StatusCompleted, models.StatusFailed, models.StatusCompleted)
+
+	var exported []*models.Payment
+	filter := &models.PaymentFilter{UserID: tc.User.UserID, Status: models.StatusCompleted}
+	err := tc.PaymentService.ExportPayments(context.Background(), filter, func(payment *models.Payment) error {
+		exported = append(exported, payment)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// oldest first, like the ledger of a day
+	assert.Equal(t, []string{seeded[0].TransactionID, seeded[2].TransactionID}, transactionIDs(exported))
+}
+
+func TestExportPaymentsStopsOnError(t *testing.T) {
+	tc := Initiate(t)
+	seedPayments(t, tc, tc.User, time.Now().Add(-time.Hour).UTC(),
+		models.StatusCompleted, models.StatusCompleted, models.StatusCompleted)
+
+	errStop := errors.New("client went away")
+	calls := 0
+	err := tc.PaymentService.ExportPayments(context.Background(), &models.PaymentFilter{UserID: tc.User.UserID}, func(*models.Payment) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
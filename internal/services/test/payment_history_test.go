@@ -0,0 +1,60 @@
+package services_test
+
+import (
+	"testing"
+
+	"payment-service/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestPaymentStatusHistory(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	_, _, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 40))
+	require.NoError(t, err)
+
+	history, err := tc.PaymentService.GetPaymentHistory(payment.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, models.PaymentStatus(""), history[0].FromStatus)
+	assert.Equal(t, models.StatusPending, history[0].ToStatus)
+	assert.Equal(t, models.StatusPending, history[1].FromStatus)
+	assert.Equal(t, models.StatusCompleted, history[1].ToStatus)
+	assert.Equal(t, models.StatusCompleted, history[2].FromStatus)
+	assert.Equal(t, models.StatusPartiallyRefunded, history[2].ToStatus)
+	assert.Equal(t, "refund rf1", history[2].Reason)
+}
+
+func TestPaymentTransitionIsGuarded(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	created := createPendingPayment(t, tc, "tx123")
+
+	// a copy read before the payment was cancelled
+	stale, err := tc.PaymentRepo.GetByID(created.ID)
+	require.NoError(t, err)
+	_, err = tc.PaymentService.CancelPayment("tx123")
+	require.NoError(t, err)
+
+	err = testDB.Transaction(func(tx *gorm.DB) error {
+		return tc.PaymentRepo.Transition(tx, stale, models.StatusCompleted, "")
+	})
+	assert.ErrorIs(t, err, models.ErrStatusChanged)
+
+	cancelled, err := tc.PaymentRepo.GetByID(created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, cancelled.Status)
+
+	err = testDB.Transaction(func(tx *gorm.DB) error {
+		return tc.PaymentRepo.Transition(tx, cancelled, models.StatusCompleted, "")
+	})
+	assert.ErrorIs(t, err, models.ErrInvalidTransition)
+
+	history, err := tc.PaymentService.GetPaymentHistory("tx123")
+	require.NoError(t, err)
+	assert.Len(t, history, 2, "rejected transitions should not be recorded")
+}
@@ -0,0 +1,115 @@
+package services_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedPayments records payments of the user with amounts 10, 20, ... created a minute apart, the oldest first.
+// They are inserted directly, so that the workers leave them alone and their status is the one given.
+func seedPayments(t *testing.T, tc *TestContext, user *models.User, start time.Time, statuses ...models.PaymentStatus) []*models.Payment {
+	payments := make([]*models.Payment, 0, len(statuses))
+	for i, status := range statuses {
+		payment := &models.Payment{
+			UserID:        user.UserID,
+			Amount:        decimal.NewFromInt(int64(10 * (i + 1))),
+			Currency:      "USD",
+			TransactionID: fmt.Sprintf("tx_%s_%d", user.UserID, i),
+			Status:        status,
+			CreatedAt:     start.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, tc.PaymentRepo.Create(testDB, payment))
+		payments = append(payments, payment)
+	}
+	return payments
+}
+
+func transactionIDs(payments []*models.Payment) []string {
+	ids := make([]string, 0, len(payments))
+	for _, payment := range payments {
+		ids = append(ids, payment.TransactionID)
+	}
+	return ids
+}
+
+func TestListPaymentsPagesThroughAll(t *testing.T) {
+	tc := Initiate(t)
+	start := time.Now().Add(-time.Hour).UTC()
+	seeded := seedPayments(t, tc, tc.User, start,
+		models.StatusCompleted, models.StatusCompleted, models.StatusFailed, models.StatusCompleted, models.StatusRefunded)
+
+	var listed []*models.Payment
+	page := &models.PageQuery{Limit: 2}
+	for pages := 1; ; pages++ {
+		payments, meta, err := tc.PaymentService.ListPayments(&models.PaymentFilter{}, page)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(payments), 2)
+		listed = append(listed, payments...)
+		if !meta.HasMore {
+			assert.Equal(t, 3, pages)
+			assert.Empty(t, meta.NextCursor)
+			break
+		}
+		page = &models.PageQuery{Limit: 2, Cursor: meta.NextCursor}
+	}
+
+	// newest first by default, every payment exactly once
+	expected := transactionIDs(seeded)
+	for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+		expected[i], expected[j] = expected[j], expected[i]
+	}
+	assert.Equal(t, expected, transactionIDs(listed))
+
+	payments, _, err := tc.PaymentService.ListPayments(&models.PaymentFilter{}, &models.PageQuery{Sort: models.SortCreatedAtAsc})
+	require.NoError(t, err)
+	assert.Equal(t, transactionIDs(seeded), transactionIDs(payments))
+}
+
+func TestListPaymentsFilters(t *testing.T) {
+	tc := Initiate(t)
+	other, err := tc.UserService.Generate()
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).UTC()
+	seeded := seedPayments(t, tc, tc.User, start,
+		models.StatusCompleted, models.StatusFailed, models.StatusCompleted, models.StatusCompleted)
+	seedPayments(t, tc, other, start, models.StatusCompleted)
+
+	minAmount, maxAmount := decimal.NewFromInt(20), decimal.NewFromInt(30)
+	from, to := seeded[1].CreatedAt, seeded[3].CreatedAt
+
+	tests := []struct {
+		name     string
+		filter   models.PaymentFilter
+		expected []*models.Payment
+	}{
+		{"user", models.PaymentFilter{UserID: tc.User.UserID}, seeded},
+		{"status", models.PaymentFilter{UserID: tc.User.UserID, Status: models.StatusFailed}, seeded[1:2]},
+		{"amount range", models.PaymentFilter{UserID: tc.User.UserID, MinAmount: &minAmount, MaxAmount: &maxAmount}, seeded[1:3]},
+		{"created range excludes its end", models.PaymentFilter{UserID: tc.User.UserID, CreatedFrom: &from, CreatedTo: &to}, seeded[1:3]},
+		{"combined", models.PaymentFilter{UserID: tc.User.UserID, Status: models.StatusCompleted, MinAmount: &minAmount}, []*models.Payment{seeded[2], seeded[3]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payments, meta, err := tc.PaymentService.ListPayments(&tt.filter, &models.PageQuery{Sort: models.SortCreatedAtAsc})
+			require.NoError(t, err)
+			assert.False(t, meta.HasMore)
+			assert.Equal(t, transactionIDs(tt.expected), transactionIDs(payments))
+		})
+	}
+}
+
+func TestListPaymentsRejectsInvalidCursor(t *testing.T) {
+	tc := Initiate(t)
+
+	_, _, err := tc.PaymentService.ListPayments(&models.PaymentFilter{}, &models.PageQuery{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, models.ErrInvalidCursor)
+}
@@ -1,14 +1,19 @@
 package services_test
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"payment-service/internal/currency"
 	"payment-service/internal/database"
+	"payment-service/internal/fx"
 	"payment-service/internal/models"
+	"payment-service/internal/processor"
+	"payment-service/internal/redis"
 	"payment-service/internal/repositories"
 	"payment-service/internal/services"
-	"slices"
+	"payment-service/internal/worker"
 	"sync"
 	"testing"
 	"time"
@@ -25,11 +30,24 @@ type TestContext struct {
 	EstimatedProcessTime time.Duration
 
 	// Dependencies
-	PaymentRepo    repositories.PaymentRepository
-	WalletRepo     repositories.WalletRepository
-	UserRepo       repositories.UserRepository
-	PaymentService services.PaymentService
-	UserService    services.UserService
+	PaymentRepo     repositories.PaymentRepository
+	PaymentJobRepo  repositories.PaymentJobRepository
+	EventRepo       repositories.ProcessorEventRepository
+	EndpointRepo    repositories.WebhookEndpointRepository
+	DeliveryRepo    repositories.WebhookDeliveryRepository
+	OutboxRepo      repositories.OutboxEventRepository
+	LedgerRepo      repositories.LedgerRepository
+	WalletRepo      repositories.WalletRepository
+	UserRepo        repositories.UserRepository
+	PaymentService  services.PaymentService
+	UserService     services.UserService
+	WebhookService  services.WebhookService
+	LedgerService   services.LedgerService
+	RefundService   services.RefundService
+	DepositService  services.DepositService
+	TransferService services.TransferService
+	FxService       services.FxService
+	Simulator       *processor.Simulator
 
 	User   *models.User
 	Wallet *models.Wallet
@@ -55,19 +73,60 @@ func TestMain(m *testing.M) {
 }
 
 func Initiate(t *testing.T) *TestContext {
+	// approve every payment without latency, so that outcomes are deterministic
+	simulator := processor.NewSimulator(processor.SimulatorOptions{Seed: 1, SuccessRate: 1})
+	tc := InitiateWithProcessor(t, simulator)
+	tc.Simulator = simulator
+	return tc
+}
+
+func InitiateWithProcessor(t *testing.T, paymentProcessor processor.PaymentProcessor) *TestContext {
 	ctx, _ := gin.CreateTestContext(nil)
 
 	// Init
 	paymentRepo := repositories.NewPaymentRepository(testDB)
 	walletRepo := repositories.NewWalletRepository(testDB)
 	userRepo := repositories.NewUserRepository(testDB)
+	lockFenceRepo := repositories.NewLockFenceRepository(testDB)
+	paymentJobRepo := repositories.NewPaymentJobRepository(testDB)
+	processorEventRepo := repositories.NewProcessorEventRepository(testDB)
+	webhookEndpointRepo := repositories.NewWebhookEndpointRepository(testDB)
+	webhookDeliveryRepo := repositories.NewWebhookDeliveryRepository(testDB)
+	webhookService := services.NewWebhookService(webhookEndpointRepo, webhookDeliveryRepo)
+	outboxEventRepo := repositories.NewOutboxEventRepository(testDB)
+	ledgerRepo := repositories.NewLedgerRepository(testDB)
+
+	locker := redis.NewLockManager(redis.DefaultLockOptions())
+
+	// fixed rates, so that converted amounts are known
+	rateProvider := fx.NewStaticProvider(map[string]decimal.Decimal{
+		fx.Pair("EUR", "USD"): decimal.RequireFromString("1.08"),
+		fx.Pair("USD", "JPY"): decimal.RequireFromString("150"),
+	})
+	fxService := services.NewFxService(fx.DefaultOptions(), repositories.NewFxQuoteRepository(testDB), rateProvider)
 
-	paymentService := services.NewPaymentService(testDB, paymentRepo, walletRepo)
-	userService := services.NewUserService(testDB, userRepo, walletRepo)
+	paymentService := services.NewPaymentService(testDB, paymentRepo, walletRepo, lockFenceRepo, paymentJobRepo, processorEventRepo, outboxEventRepo, ledgerRepo, locker, paymentProcessor, fxService)
+	userService := services.NewUserService(testDB, userRepo, walletRepo, ledgerRepo, paymentRepo, decimal.Zero)
+	ledgerService := services.NewLedgerService(testDB, ledgerRepo, walletRepo)
+	depositService := services.NewDepositService(testDB, repositories.NewDepositRepository(testDB), walletRepo, lockFenceRepo, ledgerRepo, locker)
+	transferService := services.NewTransferService(testDB, repositories.NewTransferRepository(testDB), walletRepo, lockFenceRepo, ledgerRepo, locker)
+	refundService := services.NewRefundService(testDB, repositories.NewRefundRepository(testDB), paymentRepo, walletRepo, outboxEventRepo, ledgerRepo)
 
 	// Clear old data
 	_ = database.CleanTestData()
 
+	// Start payment workers, stopped when the test ends
+	workerOptions := worker.DefaultOptions()
+	workerOptions.PollInterval = 50 * time.Millisecond
+	workerOptions.BaseBackoff = 10 * time.Millisecond
+	paymentWorkers := worker.NewPaymentWorkerPool(workerOptions, paymentJobRepo, paymentService)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	paymentWorkers.Start(workerCtx)
+	t.Cleanup(func() {
+		stopWorkers()
+		paymentWorkers.Wait()
+	})
+
 	user, err := userService.Generate()
 	assert.NoError(t, err, "should generate user and wallet successful")
 	assert.NotNil(t, user, "should create user")
@@ -75,12 +134,24 @@ func Initiate(t *testing.T) *TestContext {
 
 	return &TestContext{
 		Ctx:                  ctx,
-		EstimatedProcessTime: 4 * time.Second,
+		EstimatedProcessTime: 1 * time.Second,
 		PaymentRepo:          paymentRepo,
+		PaymentJobRepo:       paymentJobRepo,
+		EventRepo:            processorEventRepo,
+		EndpointRepo:         webhookEndpointRepo,
+		DeliveryRepo:         webhookDeliveryRepo,
+		OutboxRepo:           outboxEventRepo,
+		LedgerRepo:           ledgerRepo,
 		WalletRepo:           walletRepo,
 		UserRepo:             userRepo,
 		PaymentService:       paymentService,
 		UserService:          userService,
+		WebhookService:       webhookService,
+		LedgerService:        ledgerService,
+		RefundService:        refundService,
+		DepositService:       depositService,
+		TransferService:      transferService,
+		FxService:            fxService,
 
 		User:   user,
 		Wallet: user.Wallet,
@@ -118,15 +189,53 @@ func TestFullFlow(t *testing.T) {
 		latestPayment, err := tc.PaymentService.GetPaymentByTransactionID(req.TransactionID)
 		assert.NoError(t, err)
 
-		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID)
+		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID, currency.Default)
 		assert.NoError(t, err)
 
-		expectedStatus := []models.PaymentStatus{models.StatusCompleted, models.StatusFailed}
-		assert.True(t, slices.Contains(expectedStatus, latestPayment.Status), "Payment should be completed or failed")
+		assert.Equal(t, models.StatusCompleted, latestPayment.Status, "Payment should be completed")
+		assert.True(t, tc.Simulator.Captured(req.TransactionID), "Payment should be captured by the processor")
 
 		expectedBalance := wallet.Balance.Sub(req.Amount)
 		assert.True(t, latestWallet.Balance.Equal(expectedBalance))
 	})
+
+	t.Run("Payment job should be finished by the worker", func(t *testing.T) {
+		var job models.PaymentJob
+		assert.NoError(t, testDB.Where("payment_id = (SELECT id FROM payments WHERE transaction_id = ?)", req.TransactionID).First(&job).Error)
+		assert.Equal(t, models.JobSucceeded, job.Status)
+		assert.Equal(t, 1, job.Attempts)
+	})
+}
+
+func TestDeclinedPayment(t *testing.T) {
+	simulator := processor.NewSimulator(processor.SimulatorOptions{Seed: 1, SuccessRate: 0})
+	tc := InitiateWithProcessor(t, simulator)
+	var (
+		user   = tc.User
+		wallet = tc.Wallet
+	)
+
+	req := &models.PaymentRequest{
+		UserID:        user.UserID,
+		Amount:        decimal.NewFromInt(100),
+		TransactionID: "tx123",
+	}
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, req)
+	assert.NoError(t, err)
+	time.Sleep(tc.EstimatedProcessTime)
+
+	t.Run("Declined payment should be failed and wallet untouched", func(t *testing.T) {
+		latestPayment, err := tc.PaymentService.GetPaymentByTransactionID(req.TransactionID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusFailed, latestPayment.Status)
+		assert.Equal(t, "declined by processor", latestPayment.FailureReason)
+		assert.False(t, simulator.Captured(req.TransactionID))
+
+		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID, currency.Default)
+		assert.NoError(t, err)
+		assert.True(t, latestWallet.Balance.Equal(wallet.Balance))
+	})
 }
 
 func TestMakePaymentWithDuplicatedTransactionId(t *testing.T) {
@@ -167,7 +276,7 @@ func TestMakePaymentWithDuplicatedTransactionId(t *testing.T) {
 
 	time.Sleep(tc.EstimatedProcessTime)
 	t.Run("Validate wallet balance only deducted once", func(t *testing.T) {
-		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID)
+		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID, currency.Default)
 		assert.NoError(t, err)
 
 		expectedBalance := wallet.Balance.Sub(req.Amount)
@@ -175,6 +284,41 @@ func TestMakePaymentWithDuplicatedTransactionId(t *testing.T) {
 	})
 }
 
+func TestMakePaymentWithDuplicatedTransactionIdAndDifferentPayload(t *testing.T) {
+	tc := Initiate(t)
+	user := tc.User
+
+	req := &models.PaymentRequest{
+		UserID:        user.UserID,
+		Amount:        decimal.NewFromInt(100),
+		TransactionID: "tx123",
+	}
+
+	t.Run("First time: create new payment", func(t *testing.T) {
+		payment, err := tc.PaymentService.ProcessPayment(tc.Ctx, req)
+		assert.NoError(t, err)
+		assert.NotNil(t, payment)
+	})
+
+	t.Run("Same payload with different amount scale should return existing payment", func(t *testing.T) {
+		sameReq := *req
+		sameReq.Amount = decimal.RequireFromString("100.00")
+		payment, err := tc.PaymentService.ProcessPayment(tc.Ctx, &sameReq)
+		assert.NoError(t, err)
+		assert.NotNil(t, payment)
+	})
+
+	t.Run("Different amount should be rejected as idempotency key conflict", func(t *testing.T) {
+		conflictReq := *req
+		conflictReq.Amount = decimal.NewFromInt(200)
+		payment, err := tc.PaymentService.ProcessPayment(tc.Ctx, &conflictReq)
+		assert.Nil(t, payment)
+		assert.ErrorIs(t, err, services.ErrIdempotencyKeyConflict)
+	})
+
+	time.Sleep(tc.EstimatedProcessTime)
+}
+
 func TestProcessPaymentConcurrent(t *testing.T) {
 	tc := Initiate(t)
 	var (
@@ -233,7 +377,7 @@ func TestProcessPaymentConcurrent(t *testing.T) {
 	})
 
 	t.Run("Verify wallet balance only deducted once", func(t *testing.T) {
-		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID)
+		latestWallet, err := tc.WalletRepo.GetByUserId(user.UserID, currency.Default)
 		assert.NoError(t, err)
 
 		expectedBalance := wallet.Balance.Sub(req.Amount)
@@ -256,3 +400,118 @@ func uniquePaymentsCount(payments []*models.Payment) int {
 	}
 	return len(seen)
 }
+
+func setBalance(t *testing.T, tc *TestContext, balance int64) {
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	assert.NoError(t, err)
+	wallet.Balance = decimal.NewFromInt(balance)
+	assert.NoError(t, tc.WalletRepo.UpdateBalance(testDB, wallet))
+}
+
+func TestPaymentReservesFunds(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	setBalance(t, tc, 150)
+
+	createPendingPayment(t, tc, "tx123")
+
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	assert.NoError(t, err)
+	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(150)), "the balance is only debited on completion")
+	assert.True(t, wallet.HeldBalance.Equal(decimal.NewFromInt(100)))
+
+	_, err = tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        decimal.NewFromInt(100),
+		TransactionID: "tx456",
+	})
+	assert.ErrorIs(t, err, models.ErrInsufficientFunds, "held funds should not be available to another payment")
+
+	t.Run("Completion captures the hold", func(t *testing.T) {
+		_, _, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"})
+		assert.NoError(t, err)
+
+		wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+		assert.NoError(t, err)
+		assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(50)))
+		assert.True(t, wallet.HeldBalance.IsZero())
+	})
+}
+
+func TestFailedPaymentReleasesFunds(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	setBalance(t, tc, 150)
+
+	createPendingPayment(t, tc, "tx123")
+	_, _, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeFailed, Reference: "tx123"})
+	assert.NoError(t, err)
+
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	assert.NoError(t, err)
+	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(150)))
+	assert.True(t, wallet.HeldBalance.IsZero(), "the hold should be released")
+
+	// the released funds can be spent again
+	createPendingPayment(t, tc, "tx456")
+}
+
+func TestConcurrentPaymentsCannotOverspend(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	setBalance(t, tc, 350)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+				UserID:        tc.User.UserID,
+				Amount:        decimal.NewFromInt(100),
+				TransactionID: fmt.Sprintf("tx-%d", i),
+			})
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, models.ErrInsufficientFunds)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, created, "only the payments the balance covers should be created")
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	assert.NoError(t, err)
+	assert.True(t, wallet.HeldBalance.Equal(decimal.NewFromInt(300)), "held %s", wallet.HeldBalance)
+}
+
+func TestPaymentWithoutHoldDoesNotOverdrawWallet(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	setBalance(t, tc, 50)
+
+	// a payment created before holds existed
+	payment := &models.Payment{UserID: tc.User.UserID, Amount: decimal.NewFromInt(100), TransactionID: "tx123", Status: models.StatusPending}
+	assert.NoError(t, tc.PaymentRepo.Create(testDB, payment))
+
+	settled, _, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, settled.Status, "a payment the wallet cannot cover should fail")
+	assert.Equal(t, models.ErrInsufficientFunds.Error(), settled.FailureReason)
+
+	wallet, err := tc.WalletRepo.GetByUserId(tc.User.UserID, currency.Default)
+	assert.NoError(t, err)
+	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(50)), "wallet balance %s", wallet.Balance)
+}
+
+func TestProcessPaymentInsufficientBalance(t *testing.T) {
+	tc := Initiate(t)
+
+	_, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        tc.Wallet.Balance.Add(decimal.NewFromInt(1)),
+		TransactionID: "tx123",
+	})
+	assert.ErrorIs(t, err, models.ErrInsufficientFunds)
+}
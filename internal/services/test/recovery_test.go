@@ -0,0 +1,124 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/worker"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverySweep(t *testing.T) {
+	tc := Initiate(t)
+	auditRepo := repositories.NewPaymentRecoveryAuditRepository(testDB)
+
+	opts := worker.DefaultRecoveryOptions()
+	opts.PendingThreshold = time.Hour
+	opts.FailAfter = 24 * time.Hour
+	sweeper := worker.NewRecoverySweeper(opts, tc.PaymentRepo, tc.PaymentJobRepo, auditRepo, tc.PaymentService)
+
+	// payments left behind by a crash: pending without a job
+	newStuckPayment := func(transactionID string, age time.Duration) *models.Payment {
+		payment := &models.Payment{
+			UserID:        tc.User.UserID,
+			Amount:        decimal.NewFromInt(100),
+			TransactionID: transactionID,
+			Status:        models.StatusPending,
+			CreatedAt:     time.Now().Add(-age),
+		}
+		require.NoError(t, tc.PaymentRepo.Create(testDB, payment))
+		return payment
+	}
+	recent := newStuckPayment("tx-recent", time.Minute)
+	stuck := newStuckPayment("tx-stuck", 2*time.Hour)
+	expired := newStuckPayment("tx-expired", 48*time.Hour)
+
+	audits, err := sweeper.Sweep()
+	require.NoError(t, err)
+	assert.Len(t, audits, 2, "only payments older than the threshold should be swept")
+
+	t.Run("Recent pending payment should be left alone", func(t *testing.T) {
+		records, err := auditRepo.GetByPaymentID(recent.ID)
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+	})
+
+	t.Run("Stuck payment without job should be resumed", func(t *testing.T) {
+		records, err := auditRepo.GetByPaymentID(stuck.ID)
+		assert.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, models.RecoveryResumed, records[0].Action)
+
+		_, err = tc.PaymentJobRepo.GetByPaymentID(stuck.ID)
+		assert.NoError(t, err, "a job should be queued for the stuck payment")
+	})
+
+	t.Run("Payment pending for longer than the limit should be failed with a reason", func(t *testing.T) {
+		records, err := auditRepo.GetByPaymentID(expired.ID)
+		assert.NoError(t, err)
+		require.Len(t, records, 1)
+		assert.Equal(t, models.RecoveryFailed, records[0].Action)
+
+		payment, err := tc.PaymentRepo.GetByID(expired.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusFailed, payment.Status)
+		assert.NotEmpty(t, payment.FailureReason)
+	})
+
+	time.Sleep(tc.EstimatedProcessTime)
+	t.Run("Resumed payment should reach a terminal status", func(t *testing.T) {
+		payment, err := tc.PaymentRepo.GetByID(stuck.ID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, models.StatusPending, payment.Status)
+	})
+}
+
+func TestRecoverySweepPassesOverActiveJobs(t *testing.T) {
+	tc := Initiate(t)
+	auditRepo := repositories.NewPaymentRecoveryAuditRepository(testDB)
+
+	opts := worker.DefaultRecoveryOptions()
+	opts.PendingThreshold = time.Hour
+	opts.BatchSize = 1
+	sweeper := worker.NewRecoverySweeper(opts, tc.PaymentRepo, tc.PaymentJobRepo, auditRepo, tc.PaymentService)
+
+	newPendingPayment := func(transactionID string, age time.Duration) *models.Payment {
+		payment := &models.Payment{
+			UserID:        tc.User.UserID,
+			Amount:        decimal.NewFromInt(100),
+			TransactionID: transactionID,
+			Status:        models.StatusPending,
+			CreatedAt:     time.Now().Add(-age),
+		}
+		require.NoError(t, tc.PaymentRepo.Create(testDB, payment))
+		return payment
+	}
+
+	// the oldest payment has a job that is queued but not due yet, e.g. waiting for a retry
+	active := newPendingPayment("tx-active", 3*time.Hour)
+	require.NoError(t, tc.PaymentJobRepo.Enqueue(testDB, &models.PaymentJob{
+		PaymentID: active.ID,
+		Status:    models.JobQueued,
+		RunAt:     time.Now().Add(time.Hour),
+	}))
+	stuck := newPendingPayment("tx-stuck", 2*time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, err := sweeper.Sweep()
+		require.NoError(t, err)
+	}
+
+	records, err := auditRepo.GetByPaymentID(active.ID)
+	require.NoError(t, err)
+	assert.Empty(t, records, "a payment with an active job should not be audited")
+
+	records, err = auditRepo.GetByPaymentID(stuck.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, records, "the active job should not hold back a stuck payment")
+	assert.Equal(t, models.RecoveryResumed, records[0].Action)
+}
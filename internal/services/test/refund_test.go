@@ -0,0 +1,155 @@
+package services_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refundRequest(refundID string, amount int64) *models.RefundRequest {
+	return &models.RefundRequest{RefundID: refundID, Amount: decimal.NewFromInt(amount)}
+}
+
+func TestPartialAndFullRefund(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	_, created, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 30))
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	payment, err = tc.PaymentService.GetPaymentByTransactionID(payment.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPartiallyRefunded, payment.Status)
+	assert.True(t, payment.RefundedAmount.Equal(decimal.NewFromInt(30)))
+
+	_, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf2", 70))
+	require.NoError(t, err)
+
+	payment, err = tc.PaymentService.GetPaymentByTransactionID(payment.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusRefunded, payment.Status)
+
+	// the whole amount went back to the wallet
+	assertWalletDebited(t, tc, false)
+
+	refunds, err := tc.RefundService.GetRefunds(payment.TransactionID)
+	require.NoError(t, err)
+	assert.Len(t, refunds, 2)
+
+	ledger, err := tc.LedgerService.GetWalletLedger(tc.User.UserID, currency.Default)
+	require.NoError(t, err)
+	assert.True(t, ledger.Reconciled, "stored balance %s should match ledger balance %s", ledger.StoredBalance, ledger.LedgerBalance)
+
+	events, err := tc.OutboxRepo.ListByAggregate("payment", payment.ID)
+	require.NoError(t, err)
+	refunded := 0
+	for _, e := range events {
+		if e.EventType == models.EventPaymentRefunded {
+			refunded++
+		}
+	}
+	assert.Equal(t, 2, refunded, "every refund should publish a payment.refunded event")
+}
+
+func TestRefundCappedAtPaymentAmount(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+
+	_, _, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 101))
+	assert.ErrorIs(t, err, services.ErrRefundExceedsPayment)
+
+	_, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 60))
+	require.NoError(t, err)
+	_, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf2", 60))
+	assert.ErrorIs(t, err, services.ErrRefundExceedsPayment)
+}
+
+func TestConcurrentRefundsCannotExceedPayment(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest(fmt.Sprintf("rf%d", i), 40))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, services.ErrRefundExceedsPayment)
+		}
+	}
+	assert.Equal(t, 2, succeeded, "only two refunds of 40 fit in a payment of 100")
+
+	payment, err := tc.PaymentService.GetPaymentByTransactionID(payment.TransactionID)
+	require.NoError(t, err)
+	assert.True(t, payment.RefundedAmount.Equal(decimal.NewFromInt(80)))
+}
+
+func TestRefundIsIdempotent(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+
+	first, created, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 40))
+	require.NoError(t, err)
+	require.True(t, created)
+
+	replay, created, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 40))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, replay.ID)
+
+	payment, err = tc.PaymentService.GetPaymentByTransactionID(payment.TransactionID)
+	require.NoError(t, err)
+	assert.True(t, payment.RefundedAmount.Equal(decimal.NewFromInt(40)), "a replayed refund should not be applied twice")
+
+	_, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 50))
+	assert.ErrorIs(t, err, services.ErrRefundIDConflict)
+}
+
+func TestRefundAmountHonorsCurrencyPrecision(t *testing.T) {
+	tc := Initiate(t)
+	payment := payAndWait(t, tc)
+	require.Equal(t, models.StatusCompleted, payment.Status)
+
+	_, _, err := tc.RefundService.CreateRefund(payment.TransactionID,
+		&models.RefundRequest{RefundID: "rf1", Amount: decimal.RequireFromString("10.005")})
+	assert.ErrorIs(t, err, services.ErrRefundPrecision)
+
+	refund, _, err := tc.RefundService.CreateRefund(payment.TransactionID,
+		&models.RefundRequest{RefundID: "rf1", Amount: decimal.RequireFromString("10.05")})
+	require.NoError(t, err)
+	assert.True(t, refund.Amount.Equal(decimal.RequireFromString("10.05")))
+}
+
+func TestRefundRequiresCompletedPayment(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	payment := createPendingPayment(t, tc, "tx123")
+
+	_, _, err := tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 10))
+	assert.ErrorIs(t, err, services.ErrPaymentNotRefundable)
+
+	require.NoError(t, tc.PaymentService.FailPayment(payment.ID, "declined"))
+	_, _, err = tc.RefundService.CreateRefund(payment.TransactionID, refundRequest("rf1", 10))
+	assert.ErrorIs(t, err, services.ErrPaymentNotRefundable)
+}
@@ -0,0 +1,145 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/services"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func transferRequest(transferID string, from, to *models.User, amount int64) *models.TransferRequest {
+	return &models.TransferRequest{
+		TransferID: transferID,
+		FromUserID: from.UserID,
+		ToUserID:   to.UserID,
+		Amount:     decimal.NewFromInt(amount),
+	}
+}
+
+func balanceOf(t *testing.T, tc *TestContext, user *models.User) decimal.Decimal {
+	wallet, err := tc.WalletRepo.GetByUserId(user.UserID, currency.Default)
+	require.NoError(t, err)
+	return wallet.Balance
+}
+
+func TestTransferMovesFunds(t *testing.T) {
+	tc := Initiate(t)
+	recipient, err := tc.UserService.Generate()
+	require.NoError(t, err)
+
+	transfer, created, err := tc.TransferService.Transfer(tc.Ctx, transferRequest("tr1", tc.User, recipient, 300))
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, "tr1", transfer.TransferID)
+
+	assert.True(t, balanceOf(t, tc, tc.User).Equal(tc.Wallet.Balance.Sub(decimal.NewFromInt(300))))
+	assert.True(t, balanceOf(t, tc, recipient).Equal(recipient.Wallet.Balance.Add(decimal.NewFromInt(300))))
+
+	mismatches, err := tc.LedgerService.Reconcile()
+	require.NoError(t, err)
+	assert.Empty(t, mismatches, "both wallets should match their ledger")
+}
+
+func TestTransferIsIdempotent(t *testing.T) {
+	tc := Initiate(t)
+	recipient, err := tc.UserService.Generate()
+	require.NoError(t, err)
+
+	first, _, err := tc.TransferService.Transfer(tc.Ctx, transferRequest("tr1", tc.User, recipient, 300))
+	require.NoError(t, err)
+
+	replay, created, err := tc.TransferService.Transfer(tc.Ctx, transferRequest("tr1", tc.User, recipient, 300))
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, replay.ID)
+	assert.True(t, balanceOf(t, tc, recipient).Equal(recipient.Wallet.Balance.Add(decimal.NewFromInt(300))))
+
+	_, _, err = tc.TransferService.Transfer(tc.Ctx, transferRequest("tr1", tc.User, recipient, 400))
+	assert.ErrorIs(t, err, services.ErrTransferIDConflict)
+}
+
+func TestTransferInsufficientBalance(t *testing.T) {
+	tc := Initiate(t)
+	recipient, err := tc.UserService.Generate()
+	require.NoError(t, err)
+	setBalance(t, tc, 100)
+
+	_, _, err = tc.TransferService.Transfer(tc.Ctx, transferRequest("tr1", tc.User, recipient, 101))
+	assert.ErrorIs(t, err, models.ErrInsufficientFunds)
+	assert.True(t, balanceOf(t, tc, recipient).Equal(recipient.Wallet.Balance), "a rejected transfer should not credit the recipient")
+
+	_, _, err = tc.TransferService.Transfer(tc.Ctx, transferRequest("tr2", tc.User, tc.User, 10))
+	assert.ErrorIs(t, err, services.ErrSelfTransfer)
+}
+
+func TestConcurrentOppositeTransfersDoNotDeadlock(t *testing.T) {
+	tc := Initiate(t)
+	other, err := tc.UserService.Generate()
+	require.NoError(t, err)
+
+	const rounds = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*rounds)
+	for i := 0; i < rounds; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := tc.TransferService.Transfer(tc.Ctx, transferRequest(fmt.Sprintf("ab%d", i), tc.User, other, 10))
+			errs <- err
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := tc.TransferService.Transfer(tc.Ctx, transferRequest(fmt.Sprintf("ba%d", i), other, tc.User, 10))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	// every transfer was matched by one in the opposite direction
+	assert.True(t, balanceOf(t, tc, tc.User).Equal(tc.Wallet.Balance))
+	assert.True(t, balanceOf(t, tc, other).Equal(other.Wallet.Balance))
+}
+
+func TestConcurrentTransfersCannotOverspend(t *testing.T) {
+	tc := Initiate(t)
+	recipient, err := tc.UserService.Generate()
+	require.NoError(t, err)
+	setBalance(t, tc, 100)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, err := tc.TransferService.Transfer(tc.Ctx, transferRequest(fmt.Sprintf("tr%d", i), tc.User, recipient, 30))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else if !errors.Is(err, models.ErrInsufficientFunds) {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	assert.Equal(t, 3, succeeded, "only three transfers of 30 fit in a balance of 100")
+	assert.True(t, balanceOf(t, tc, tc.User).Equal(decimal.NewFromInt(10)))
+	assert.True(t, balanceOf(t, tc, recipient).Equal(recipient.Wallet.Balance.Add(decimal.NewFromInt(90))))
+}
@@ -0,0 +1,101 @@
+package services_test
+
+import (
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestUserPaymentsRunningTotals(t *testing.T) {
+	tc := Initiate(t)
+	other, err := tc.UserService.Generate()
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).UTC()
+	seeded := seedPayments(t, tc, tc.User, start,
+		models.StatusCompleted, models.StatusFailed, models.StatusCompleted, models.StatusCompleted)
+	seedPayments(t, tc, other, start, models.StatusCompleted)
+
+	// amounts are 10, 20, 30 and 40, newest first, the failed 20 was never paid
+	var listed []*models.UserPayment
+	query := &models.UserPaymentQuery{PageQuery: models.PageQuery{Limit: 3}}
+	for {
+		payments, page, err := tc.UserService.GetPayments(tc.User.UserID, query)
+		require.NoError(t, err)
+		listed = append(listed, payments...)
+		if !page.HasMore {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	require.Len(t, listed, len(seeded))
+	for i, expected := range []int64{80, 40, 10, 10} {
+		assert.Equal(t, seeded[len(seeded)-1-i].TransactionID, listed[i].TransactionID)
+		assert.True(t, listed[i].RunningTotal.Equal(decimal.NewFromInt(expected)), "running total %s, expected %d", listed[i].RunningTotal, expected)
+	}
+}
+
+func TestUserPaymentsStatusFilter(t *testing.T) {
+	tc := Initiate(t)
+	start := time.Now().Add(-time.Hour).UTC()
+	seeded := seedPayments(t, tc, tc.User, start,
+		models.StatusCompleted, models.StatusFailed, models.StatusCompleted)
+
+	query := &models.UserPaymentQuery{Status: models.StatusCompleted, PageQuery: models.PageQuery{Sort: models.SortCreatedAtAsc}}
+	payments, page, err := tc.UserService.GetPayments(tc.User.UserID, query)
+	require.NoError(t, err)
+	assert.False(t, page.HasMore)
+
+	require.Len(t, payments, 2)
+	assert.Equal(t, seeded[0].TransactionID, payments[0].TransactionID)
+	assert.Equal(t, seeded[2].TransactionID, payments[1].TransactionID)
+	assert.True(t, payments[1].RunningTotal.Equal(decimal.NewFromInt(40)), "the failed payment is not counted")
+
+	query = &models.UserPaymentQuery{Status: models.StatusFailed}
+	payments, _, err = tc.UserService.GetPayments(tc.User.UserID, query)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, seeded[1].TransactionID, payments[0].TransactionID)
+	assert.True(t, payments[0].RunningTotal.IsZero(), "a failed payment moved no money")
+}
+
+func TestUserPaymentsRunningTotalsDeductRefunds(t *testing.T) {
+	tc := Initiate(t)
+	seeded := seedPayments(t, tc, tc.User, time.Now().Add(-time.Hour).UTC(),
+		models.StatusCompleted, models.StatusPartiallyRefunded, models.StatusRefunded, models.StatusPending, models.StatusCancelled)
+	require.NoError(t, testDB.Model(seeded[1]).Update("refunded_amount", decimal.NewFromInt(5)).Error)
+	require.NoError(t, testDB.Model(seeded[2]).Update("refunded_amount", seeded[2].Amount).Error)
+
+	query := &models.UserPaymentQuery{PageQuery: models.PageQuery{Sort: models.SortCreatedAtAsc}}
+	payments, _, err := tc.UserService.GetPayments(tc.User.UserID, query)
+	require.NoError(t, err)
+	require.Len(t, payments, len(seeded))
+
+	// 10, then 20 less 5 refunded, then 30 fully refunded, then pending and cancelled payments that moved nothing
+	for i, expected := range []int64{10, 25, 25, 25, 25} {
+		assert.True(t, payments[i].RunningTotal.Equal(decimal.NewFromInt(expected)), "running total %s, expected %d", payments[i].RunningTotal, expected)
+	}
+
+	query.Status = models.StatusPartiallyRefunded
+	payments, _, err = tc.UserService.GetPayments(tc.User.UserID, query)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.True(t, payments[0].RunningTotal.Equal(decimal.NewFromInt(15)), "only the listed payments are summed")
+}
+
+func TestUserPaymentsOfUnknownUser(t *testing.T) {
+	tc := Initiate(t)
+
+	_, _, err := tc.UserService.GetPayments("no-such-user", &models.UserPaymentQuery{})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	_, err = tc.UserService.GetUserDetail("no-such-user")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
@@ -0,0 +1,155 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/processor"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// webhookOnlyProcessor never decides in-process, the result of every payment is reported by the webhook.
+type webhookOnlyProcessor struct{}
+
+func (webhookOnlyProcessor) Authorize(context.Context, processor.ChargeRequest) (*processor.Authorization, error) {
+	return nil, errors.New("result is reported by webhook")
+}
+
+func (webhookOnlyProcessor) Capture(context.Context, *processor.Authorization) error { return nil }
+
+func (webhookOnlyProcessor) Void(context.Context, *processor.Authorization) error { return nil }
+
+func createPendingPayment(t *testing.T, tc *TestContext, transactionID string) *models.Payment {
+	payment, err := tc.PaymentService.ProcessPayment(tc.Ctx, &models.PaymentRequest{
+		UserID:        tc.User.UserID,
+		Amount:        decimal.NewFromInt(100),
+		TransactionID: transactionID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, payment.Status)
+	return payment
+}
+
+func TestWebhookCompletionDuringAuthorizationKeepsCharge(t *testing.T) {
+	p := &gatedProcessor{gate: make(chan struct{})}
+	tc := InitiateWithProcessor(t, p)
+	payment := createPendingPayment(t, tc, "tx123")
+
+	// the webhook reports the charge while the worker is still waiting for the authorization
+	time.Sleep(200 * time.Millisecond)
+	event := &models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"}
+	_, _, err := tc.PaymentService.ApplyProcessorEvent(event)
+	require.NoError(t, err)
+	close(p.gate)
+
+	require.Eventually(t, func() bool {
+		job, err := tc.PaymentJobRepo.GetByPaymentID(payment.ID)
+		return err == nil && job.Status == models.JobSucceeded
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.Zero(t, p.voided.Load(), "the charge of a completed payment should not be voided")
+
+	payment, err = tc.PaymentService.GetPaymentByTransactionID("tx123")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+	assertWalletDebited(t, tc, true)
+}
+
+func TestProcessorEventCompletesPayment(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+
+	event := &models.ProcessorEventRequest{EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123"}
+	payment, applied, err := tc.PaymentService.ApplyProcessorEvent(event)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+	assertWalletDebited(t, tc, true)
+
+	t.Run("Redelivered event is applied once", func(t *testing.T) {
+		payment, applied, err := tc.PaymentService.ApplyProcessorEvent(event)
+		assert.NoError(t, err)
+		assert.False(t, applied)
+		assert.Equal(t, models.StatusCompleted, payment.Status)
+		assertWalletDebited(t, tc, true)
+	})
+
+	t.Run("Later failure event does not undo the completion", func(t *testing.T) {
+		payment, applied, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+			EventID: "evt_2", Type: models.EventChargeFailed, Reference: "tx123", DeclineReason: "card declined",
+		})
+		assert.NoError(t, err)
+		assert.True(t, applied)
+		assert.Equal(t, models.StatusCompleted, payment.Status)
+		assertWalletDebited(t, tc, true)
+
+		recorded, err := tc.EventRepo.GetByEventID("evt_2")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.ID, recorded.PaymentID)
+	})
+}
+
+func TestProcessorEventFailsPayment(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+
+	payment, applied, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+		EventID: "evt_1", Type: models.EventChargeFailed, Reference: "tx123", DeclineReason: "card declined",
+	})
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, models.StatusFailed, payment.Status)
+	assert.Equal(t, "card declined", payment.FailureReason)
+	assertWalletDebited(t, tc, false)
+}
+
+func TestProcessorEventUnknownReference(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+
+	_, applied, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+		EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "unknown",
+	})
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.False(t, applied)
+}
+
+func TestProcessorEventConcurrentDelivery(t *testing.T) {
+	tc := InitiateWithProcessor(t, webhookOnlyProcessor{})
+	createPendingPayment(t, tc, "tx123")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	appliedCount := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, applied, err := tc.PaymentService.ApplyProcessorEvent(&models.ProcessorEventRequest{
+				EventID: "evt_1", Type: models.EventChargeSucceeded, Reference: "tx123",
+			})
+			assert.NoError(t, err)
+			if applied {
+				mu.Lock()
+				appliedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, appliedCount, "the event should be applied exactly once")
+	assertWalletDebited(t, tc, true)
+
+	// the worker sees the settled payment on its next attempt and finishes the job
+	time.Sleep(tc.EstimatedProcessTime)
+	payment, err := tc.PaymentService.GetPaymentByTransactionID("tx123")
+	assert.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, payment.Status)
+}
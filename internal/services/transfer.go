@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"payment-service/internal/currency"
+	"payment-service/internal/models"
+	"payment-service/internal/redis"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+type TransferService interface {
+	Transfer(ctx context.Context, req *models.TransferRequest) (*models.Transfer, bool, error)
+	GetTransfer(transferID string) (*models.Transfer, error)
+}
+
+type transferService struct {
+	logger       logger.Logger
+	db           *gorm.DB
+	locker       redis.Locker
+	transferRepo repositories.TransferRepository
+	walletRepo   repositories.WalletRepository
+	fenceRepo    repositories.LockFenceRepository
+	ledgerRepo   repositories.LedgerRepository
+}
+
+func NewTransferService(
+	db *gorm.DB,
+	transferRepo repositories.TransferRepository,
+	walletRepo repositories.WalletRepository,
+	fenceRepo repositories.LockFenceRepository,
+	ledgerRepo repositories.LedgerRepository,
+	locker redis.Locker,
+) TransferService {
+	return &transferService{
+		logger:       logger.Logger{},
+		db:           db,
+		locker:       locker,
+		transferRepo: transferRepo,
+		walletRepo:   walletRepo,
+		fenceRepo:    fenceRepo,
+		ledgerRepo:   ledgerRepo,
+	}
+}
+
+// Transfer moves the amount of the request from the sender's wallet to the recipient's wallet in the same currency,
+// it fails with ErrCurrencyMismatch if either user has no wallet in the currency.
+// Like ProcessPayment it holds the lock of the transfer ID, and the transfer is only written if the lock's fencing
+// token is still the newest one for the key. A transfer ID that was already used with the same payload returns the
+// existing transfer and false, with a different payload it fails with ErrTransferIDConflict; while it is locked by a
+// concurrent request it fails with ErrTransferInProgress unless the transfer is already recorded.
+// Both wallets are locked in the order of their user IDs, so that concurrent transfers in opposite directions
+// cannot deadlock. The debit, the credit, their ledger posting and the transfer record are written in one transaction,
+// a sender whose available balance does not cover the amount fails with models.ErrInsufficientFunds and nothing is written.
+func (s *transferService) Transfer(ctx context.Context, req *models.TransferRequest) (*models.Transfer, bool, error) {
+	if req.FromUserID == req.ToUserID {
+		return nil, false, ErrSelfTransfer
+	}
+	code := currency.OrDefault(req.Currency)
+	for _, userID := range []string{req.FromUserID, req.ToUserID} {
+		if _, err := getWallet(s.walletRepo, userID, code); err != nil {
+			return nil, false, err
+		}
+	}
+
+	lockKey := "transfer:" + req.TransferID
+	lock, err := s.locker.Acquire(ctx, lockKey)
+	if err != nil {
+		if errors.Is(err, redis.ErrLockNotAcquired) {
+			existing, err := s.getExisting(req)
+			if err != nil {
+				return nil, false, err
+			}
+			if existing == nil {
+				return nil, false, ErrTransferInProgress
+			}
+			return existing, false, nil
+		}
+		return nil, false, err
+	}
+	defer func() {
+		if err := s.locker.Unlock(ctx, lock); err != nil {
+			s.logger.Error(err, "Failed to release the lock")
+		}
+	}()
+
+	if existing, err := s.getExisting(req); err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	transfer := &models.Transfer{
+		TransferID:  req.TransferID,
+		FromUserID:  req.FromUserID,
+		ToUserID:    req.ToUserID,
+		Amount:      req.Amount,
+		Currency:    code,
+		Description: req.Description,
+		RequestHash: req.Hash(),
+	}
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.fenceRepo.Advance(tx, lockKey, lock.Fence); err != nil {
+			return err
+		}
+
+		from, to, err := s.lockWallets(tx, req.FromUserID, req.ToUserID, code)
+		if err != nil {
+			return err
+		}
+		if err := from.Debit(req.Amount); err != nil {
+			return err
+		}
+		if err := to.Credit(req.Amount); err != nil {
+			return err
+		}
+		if err := s.walletRepo.UpdateBalance(tx, from); err != nil {
+			return err
+		}
+		if err := s.walletRepo.UpdateBalance(tx, to); err != nil {
+			return err
+		}
+
+		if err := s.transferRepo.Create(tx, transfer); err != nil {
+			return err
+		}
+		posting := transferPosting(fmt.Sprintf("transfer:%d", transfer.ID), from, to, transfer.Amount,
+			"transfer "+transfer.TransferID)
+		return s.ledgerRepo.Post(tx, posting)
+	}); err != nil {
+		s.logger.Error(err, "Failed to transfer")
+		return nil, false, err
+	}
+
+	return transfer, true, nil
+}
+
+// lockWallets locks the wallets of both users in the currency in the order of their user IDs and returns them as sender and recipient.
+// Every transfer takes the locks in the same global order, so two transfers never wait for each other's lock.
+func (s *transferService) lockWallets(tx *gorm.DB, fromUserID, toUserID, code string) (*models.Wallet, *models.Wallet, error) {
+	first, second := fromUserID, toUserID
+	if second < first {
+		first, second = second, first
+	}
+
+	firstWallet, err := s.walletRepo.GetForUpdate(tx, first, code)
+	if err != nil {
+		return nil, nil, err
+	}
+	secondWallet, err := s.walletRepo.GetForUpdate(tx, second, code)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if first == fromUserID {
+		return firstWallet, secondWallet, nil
+	}
+	return secondWallet, firstWallet, nil
+}
+
+// getExisting returns the transfer with the transfer ID of the request, or nil if there is none.
+// It fails with ErrTransferIDConflict if the transfer was created from a different request.
+func (s *transferService) getExisting(req *models.TransferRequest) (*models.Transfer, error) {
+	existing, err := s.transferRepo.GetByTransferID(req.TransferID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if existing.RequestHash != req.Hash() {
+		return nil, ErrTransferIDConflict
+	}
+	return existing, nil
+}
+
+func (s *transferService) GetTransfer(transferID string) (*models.Transfer, error) {
+	return s.transferRepo.GetByTransferID(transferID)
+}
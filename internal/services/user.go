@@ -1,6 +1,10 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+
+	"payment-service/internal/currency"
 	"payment-service/internal/models"
 	"payment-service/internal/repositories"
 	"payment-service/internal/utils/logger"
@@ -16,25 +20,38 @@ type UserService interface {
 	GetAll() ([]*models.User, error)
 	GetByUserId(userId string) (*models.User, error)
 	GetUserDetail(userId string) (*models.User, error)
+	CreateWallet(userId string, currency string) (*models.Wallet, error)
+	GetWallets(userId string) ([]*models.Wallet, error)
+	GetPayments(userId string, query *models.UserPaymentQuery) ([]*models.UserPayment, *models.Page, error)
 }
 
 type userService struct {
-	logger     logger.Logger
-	db         *gorm.DB
-	userRepo   repositories.UserRepository
-	walletRepo repositories.WalletRepository
+	logger      logger.Logger
+	db          *gorm.DB
+	userRepo    repositories.UserRepository
+	walletRepo  repositories.WalletRepository
+	ledgerRepo  repositories.LedgerRepository
+	paymentRepo repositories.PaymentRepository
+	// overdraftLimit is given to every wallet the service creates
+	overdraftLimit decimal.Decimal
 }
 
 func NewUserService(
 	db *gorm.DB,
 	userRepo repositories.UserRepository,
 	walletRepo repositories.WalletRepository,
+	ledgerRepo repositories.LedgerRepository,
+	paymentRepo repositories.PaymentRepository,
+	overdraftLimit decimal.Decimal,
 ) UserService {
 	return &userService{
-		logger:     logger.Logger{},
-		db:         db,
-		userRepo:   userRepo,
-		walletRepo: walletRepo,
+		logger:         logger.Logger{},
+		db:             db,
+		userRepo:       userRepo,
+		walletRepo:     walletRepo,
+		ledgerRepo:     ledgerRepo,
+		paymentRepo:    paymentRepo,
+		overdraftLimit: overdraftLimit,
 	}
 }
 
@@ -46,8 +63,10 @@ func (s *userService) Generate() (*models.User, error) {
 		UserID: uuid.NewString(), // 自动生成唯一 user_id
 	}
 	wallet := &models.Wallet{
-		UserID:  user.UserID,
-		Balance: DEFAULT_BALANCE,
+		UserID:         user.UserID,
+		Currency:       currency.Default,
+		Balance:        DEFAULT_BALANCE,
+		OverdraftLimit: s.overdraftLimit,
 	}
 
 	if err := s.db.Transaction(func(tx *gorm.DB) error {
@@ -58,12 +77,16 @@ func (s *userService) Generate() (*models.User, error) {
 			return err
 		}
 
-		return nil
+		// the opening balance is funded from outside, so that the wallet balance matches its ledger
+		posting := walletPosting(fmt.Sprintf("wallet:%d:opening", wallet.ID), wallet, models.Credit, wallet.Balance,
+			models.AccountFunding, "opening balance", nil)
+		return s.ledgerRepo.Post(tx, posting)
 	}); err != nil {
 		return nil, err
 	}
 
 	user.Wallet = wallet
+	user.Wallets = []*models.Wallet{wallet}
 	return user, nil
 }
 
@@ -76,10 +99,82 @@ func (s *userService) GetByUserId(userId string) (*models.User, error) {
 }
 
 func (s *userService) GetUserDetail(userId string) (*models.User, error) {
-	user, err := s.userRepo.GetByUserId(userId, "Wallet")
+	user, err := s.userRepo.GetByUserId(userId, "Wallets")
 	if err != nil {
 		return nil, err
 	}
 
+	for _, wallet := range user.Wallets {
+		if wallet.Currency == currency.Default {
+			user.Wallet = wallet
+		}
+	}
 	return user, nil
 }
+
+// CreateWallet opens an empty wallet for the user in the currency, funds come in through deposits and transfers.
+// It fails with ErrWalletExists if the user already has a wallet in the currency.
+func (s *userService) CreateWallet(userId string, code string) (*models.Wallet, error) {
+	if _, err := s.userRepo.GetByUserId(userId); err != nil {
+		return nil, err
+	}
+
+	wallet := &models.Wallet{
+		UserID:         userId,
+		Currency:       code,
+		Balance:        decimal.Zero,
+		OverdraftLimit: s.overdraftLimit,
+	}
+	if err := s.walletRepo.Create(s.db, wallet); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateWallet) {
+			return nil, ErrWalletExists
+		}
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (s *userService) GetWallets(userId string) ([]*models.Wallet, error) {
+	if _, err := s.userRepo.GetByUserId(userId); err != nil {
+		return nil, err
+	}
+	return s.walletRepo.ListByUserId(userId)
+}
+
+// GetPayments returns a page of the user's payments with their running totals, it fails with gorm.ErrRecordNotFound
+// if the user does not exist and with models.ErrInvalidCursor for a cursor that is not the next cursor of a page.
+func (s *userService) GetPayments(userId string, query *models.UserPaymentQuery) ([]*models.UserPayment, *models.Page, error) {
+	if _, err := s.userRepo.GetByUserId(userId); err != nil {
+		return nil, nil, err
+	}
+
+	after, err := models.DecodeCursor(query.Cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payments, err := s.paymentRepo.ListByUser(userId, query.Status, &query.PageQuery, after)
+	if err != nil {
+		return nil, nil, err
+	}
+	payments, page := paginate(payments, &query.PageQuery)
+	return payments, page, nil
+}
+
+// getWallet returns the user's wallet in the currency. It fails with ErrCurrencyMismatch if the user has wallets,
+// but none in the currency, and with gorm.ErrRecordNotFound if the user has no wallet at all.
+func getWallet(walletRepo repositories.WalletRepository, userID string, code string) (*models.Wallet, error) {
+	wallet, err := walletRepo.GetByUserId(userID, code)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return wallet, err
+	}
+
+	wallets, err := walletRepo.ListByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return nil, ErrCurrencyMismatch
+}
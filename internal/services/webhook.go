@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+	"payment-service/internal/webhook"
+)
+
+type WebhookService interface {
+	RegisterEndpoint(req *models.WebhookEndpointRequest) (*models.WebhookEndpoint, error)
+	GetEndpoints() ([]*models.WebhookEndpoint, error)
+	GetEndpoint(id uint) (*models.WebhookEndpoint, error)
+	DeactivateEndpoint(id uint) error
+	GetDeliveries(endpointID uint) ([]*models.WebhookDelivery, error)
+	GetDelivery(id uint) (*models.WebhookDelivery, error)
+	Redeliver(id uint) (*models.WebhookDelivery, error)
+	RedeliverFailed(endpointID uint) (int64, error)
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+type webhookService struct {
+	logger       logger.Logger
+	endpointRepo repositories.WebhookEndpointRepository
+	deliveryRepo repositories.WebhookDeliveryRepository
+}
+
+func NewWebhookService(
+	endpointRepo repositories.WebhookEndpointRepository,
+	deliveryRepo repositories.WebhookDeliveryRepository,
+) WebhookService {
+	return &webhookService{
+		logger:       logger.Logger{},
+		endpointRepo: endpointRepo,
+		deliveryRepo: deliveryRepo,
+	}
+}
+
+// RegisterEndpoint registers a merchant URL with a new signing secret, the secret is only returned here.
+func (s *webhookService) RegisterEndpoint(req *models.WebhookEndpointRequest) (*models.WebhookEndpoint, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		URL:    req.URL,
+		Secret: secret,
+		Active: true,
+	}
+	if err := s.endpointRepo.Create(endpoint); err != nil {
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *webhookService) GetEndpoints() ([]*models.WebhookEndpoint, error) {
+	endpoints, err := s.endpointRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, endpoint := range endpoints {
+		endpoint.Secret = ""
+	}
+	return endpoints, nil
+}
+
+func (s *webhookService) GetEndpoint(id uint) (*models.WebhookEndpoint, error) {
+	endpoint, err := s.endpointRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Secret = ""
+	return endpoint, nil
+}
+
+// DeactivateEndpoint stops deliveries to the endpoint, pending deliveries are failed on their next attempt.
+func (s *webhookService) DeactivateEndpoint(id uint) error {
+	return s.endpointRepo.Deactivate(id)
+}
+
+func (s *webhookService) GetDeliveries(endpointID uint) ([]*models.WebhookDelivery, error) {
+	if _, err := s.endpointRepo.GetByID(endpointID); err != nil {
+		return nil, err
+	}
+	return s.deliveryRepo.ListByEndpointID(endpointID)
+}
+
+func (s *webhookService) GetDelivery(id uint) (*models.WebhookDelivery, error) {
+	return s.deliveryRepo.GetByID(id)
+}
+
+// Redeliver queues a delivery to be sent again now, with a fresh retry schedule.
+// The event keeps its ID, so receivers can still drop it if they already processed it.
+func (s *webhookService) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	return s.deliveryRepo.Redeliver(id)
+}
+
+// RedeliverFailed queues every failed delivery of an endpoint again, e.g. after the merchant fixed an outage.
+func (s *webhookService) RedeliverFailed(endpointID uint) (int64, error) {
+	if _, err := s.endpointRepo.GetByID(endpointID); err != nil {
+		return 0, err
+	}
+	return s.deliveryRepo.RedeliverFailed(endpointID)
+}
+
+// Publish queues an outbox event for every active endpoint, it is the sink of the outbox relay that feeds
+// the merchant webhooks. Publishing the same event again does not queue it twice.
+func (s *webhookService) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	endpoints, err := s.endpointRepo.ListActive()
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	deliveries := make([]*models.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, &models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       event.EventID,
+			EventType:     event.EventType,
+			PaymentID:     event.AggregateID,
+			Payload:       event.Payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	return s.deliveryRepo.CreateIfAbsent(deliveries)
+}
@@ -6,10 +6,25 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Error codes returned in APIResponse.Code
+const (
+	CodeIdempotencyKeyConflict   = "idempotency_key_conflict"
+	CodeIdempotencyKeyInProgress = "idempotency_key_in_progress"
+	CodePaymentNotCancellable    = "payment_not_cancellable"
+	CodePaymentNotRefundable     = "payment_not_refundable"
+	CodeRefundExceedsPayment     = "refund_exceeds_payment"
+	CodeCurrencyMismatch         = "currency_mismatch"
+	CodeQuoteInvalid             = "quote_invalid"
+	CodeQuoteExpired             = "quote_expired"
+	CodeFxRateUnavailable        = "fx_rate_unavailable"
+)
+
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
+	Code    string      `json:"code,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	Page    interface{} `json:"page,omitempty"` // metadata of a paginated list in Data
 	Error   string      `json:"error,omitempty"`
 }
 
@@ -21,6 +36,16 @@ func SuccessResponse(c *gin.Context, statusCode int, message string, data interf
 	})
 }
 
+// PageResponse is a SuccessResponse of one page of a list, with the page metadata next to the data.
+func PageResponse(c *gin.Context, statusCode int, message string, data interface{}, page interface{}) {
+	c.JSON(statusCode, APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+		Page:    page,
+	})
+}
+
 func ErrorResponse(c *gin.Context, statusCode int, message string, err error) {
 	response := APIResponse{
 		Success: false,
@@ -34,6 +59,21 @@ func ErrorResponse(c *gin.Context, statusCode int, message string, err error) {
 	c.JSON(statusCode, response)
 }
 
+// ErrorCodeResponse is an ErrorResponse with a machine-readable error code, for errors clients are expected to handle.
+func ErrorCodeResponse(c *gin.Context, statusCode int, code string, message string, err error) {
+	response := APIResponse{
+		Success: false,
+		Message: message,
+		Code:    code,
+	}
+
+	if err != nil {
+		response.Error = err.Error()
+	}
+
+	c.JSON(statusCode, response)
+}
+
 func ValidationErrorResponse(c *gin.Context, err error) {
 	ErrorResponse(c, http.StatusBadRequest, "Validation failed", err)
 }
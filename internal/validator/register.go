@@ -9,11 +9,15 @@ var validatorEngine *validator.Validate
 
 const (
 	DecimalGreaterThan string = "decimalGt"
+	DecimalPrecision   string = "decimalPrecision"
+	CurrencyCode       string = "currency"
 )
 
 func RegisterValidators() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		_ = v.RegisterValidation(DecimalGreaterThan, DecimalGt)
+		_ = v.RegisterValidation(DecimalPrecision, DecimalMinorUnits)
+		_ = v.RegisterValidation(CurrencyCode, Currency)
 		validatorEngine = v
 	}
 }
@@ -1,6 +1,8 @@
 package validator
 
 import (
+	"payment-service/internal/currency"
+
 	"github.com/go-playground/validator/v10"
 	"github.com/shopspring/decimal"
 )
@@ -24,3 +26,28 @@ func DecimalGt(fl validator.FieldLevel) bool {
 
 	return value.GreaterThan(minimumValue)
 }
+
+// Amount has no more digits after the decimal point than its currency allows,
+// the param names the currency field of the same struct, an empty currency is the default one.
+func DecimalMinorUnits(fl validator.FieldLevel) bool {
+	value, ok := fl.Field().Interface().(decimal.Decimal)
+	if !ok {
+		return false
+	}
+
+	code := ""
+	if field := fl.Parent().FieldByName(fl.Param()); field.IsValid() {
+		code, _ = field.Interface().(string)
+	}
+
+	return currency.HasValidPrecision(value, currency.OrDefault(code))
+}
+
+// ISO-4217 code of a currency the service accepts
+func Currency(fl validator.FieldLevel) bool {
+	code, ok := fl.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+	return currency.IsValid(code)
+}
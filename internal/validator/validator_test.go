@@ -64,3 +64,42 @@ func isValidationError(err error) bool {
 	_, ok := err.(validator.ValidationErrors)
 	return ok
 }
+
+func TestDecimalMinorUnits(t *testing.T) {
+	customValidator.RegisterValidators()
+	validatorEngine := customValidator.GetValidator()
+
+	type TestStruct struct {
+		Amount   decimal.Decimal `binding:"decimalPrecision=Currency"`
+		Currency string          `binding:"omitempty,currency"`
+	}
+
+	tests := []struct {
+		name     string
+		amount   string
+		currency string
+		expected bool
+	}{
+		{"cents in USD", "10.25", "USD", true},
+		{"sub-cent in USD", "10.255", "USD", false},
+		{"whole yen", "1000", "JPY", true},
+		{"fractional yen", "1000.5", "JPY", false},
+		{"three decimals in KWD", "1.125", "KWD", true},
+		{"default currency", "10.25", "", true},
+		{"sub-cent in default currency", "10.255", "", false},
+		{"unknown currency", "10", "ABC", false},
+		{"lowercase currency", "10", "usd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatorEngine.Struct(TestStruct{Amount: decimal.RequireFromString(tt.amount), Currency: tt.currency})
+			if tt.expected {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.True(t, isValidationError(err), "error type is not validation error")
+			}
+		})
+	}
+}
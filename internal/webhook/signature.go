@@ -0,0 +1,68 @@
+// Package webhook signs and verifies webhook requests.
+// A request carries the unix time it was sent at and the hex encoded HMAC-SHA256 of "<timestamp>.<body>"
+// with a shared secret. Signing the timestamp together with the body prevents a captured request from
+// being replayed later.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Headers of a webhook sent by this service
+const (
+	IDHeader        = "X-Webhook-Id"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+var (
+	ErrSecretMissing    = errors.New("webhook secret is not configured")
+	ErrSignatureInvalid = errors.New("webhook signature is invalid")
+	ErrTimestampInvalid = errors.New("webhook timestamp is missing or outside the tolerance")
+)
+
+// Sign returns the signature of body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and the timestamp header values of a request.
+// The timestamp must not be further than tolerance away from now.
+func Verify(secret, signature, timestamp string, body []byte, now time.Time, tolerance time.Duration) error {
+	if secret == "" {
+		return ErrSecretMissing
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrTimestampInvalid
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrTimestampInvalid
+	}
+
+	expected := Sign(secret, unix, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// NewSecret returns a random secret for a new endpoint.
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,109 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/outbox"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+)
+
+type OutboxOptions struct {
+	PollInterval      time.Duration // how long the relay sleeps when the outbox is empty
+	BatchSize         int           // maximum number of events claimed at once
+	BaseBackoff       time.Duration // delay before the first retry of an event, doubled for every further attempt
+	MaxBackoff        time.Duration // upper bound of the retry delay
+	VisibilityTimeout time.Duration // claimed events are claimed again if the relay did not report back in time
+}
+
+func DefaultOutboxOptions() OutboxOptions {
+	return OutboxOptions{
+		PollInterval:      500 * time.Millisecond,
+		BatchSize:         100,
+		BaseBackoff:       1 * time.Second,
+		MaxBackoff:        5 * time.Minute,
+		VisibilityTimeout: 1 * time.Minute,
+	}
+}
+
+// OutboxRelay publishes the events of the outbox_events table to a sink.
+// An event is marked published only after the sink accepted it, so every event is published at least once;
+// a failed event is retried with exponential backoff and never given up.
+type OutboxRelay struct {
+	logger     logger.Logger
+	opts       OutboxOptions
+	outboxRepo repositories.OutboxEventRepository
+	sink       outbox.Sink
+	done       chan struct{}
+}
+
+func NewOutboxRelay(opts OutboxOptions, outboxRepo repositories.OutboxEventRepository, sink outbox.Sink) *OutboxRelay {
+	return &OutboxRelay{
+		logger:     logger.Logger{},
+		opts:       opts,
+		outboxRepo: outboxRepo,
+		sink:       sink,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start launches the relay, it stops when ctx is done. Use Wait to wait for it to finish.
+func (r *OutboxRelay) Start(ctx context.Context) {
+	go func() {
+		defer close(r.done)
+
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if r.RunOnce(ctx) > 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.opts.PollInterval):
+			}
+		}
+	}()
+}
+
+// Wait blocks until the relay stopped, a batch in progress is finished first.
+func (r *OutboxRelay) Wait() {
+	<-r.done
+}
+
+// RunOnce claims and publishes one batch of events, it returns how many events were claimed.
+func (r *OutboxRelay) RunOnce(ctx context.Context) int {
+	events, err := r.outboxRepo.ClaimBatch(r.opts.BatchSize, r.opts.VisibilityTimeout)
+	if err != nil {
+		r.logger.Error(err, "Failed to claim outbox events")
+		return 0
+	}
+
+	for _, event := range events {
+		r.publish(ctx, event)
+	}
+	return len(events)
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, event *models.OutboxEvent) {
+	if err := r.sink.Publish(ctx, event); err != nil {
+		r.logger.Error(err, fmt.Sprintf("Failed to publish outbox event %s", event.EventID))
+		nextAttemptAt := time.Now().Add(backoff(r.opts.BaseBackoff, r.opts.MaxBackoff, event.Attempts))
+		if err := r.outboxRepo.Reschedule(event, nextAttemptAt, err.Error()); err != nil {
+			r.logger.Error(err, "Failed to reschedule outbox event")
+		}
+		return
+	}
+
+	if err := r.outboxRepo.MarkPublished(event); err != nil {
+		// the event is claimed again after the visibility timeout and published twice, consumers drop it by its ID
+		r.logger.Error(err, "Failed to mark outbox event published")
+	}
+}
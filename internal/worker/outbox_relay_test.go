@@ -0,0 +1,152 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeOutboxRepo is an in-memory OutboxEventRepository.
+type fakeOutboxRepo struct {
+	mu     sync.Mutex
+	events []*models.OutboxEvent
+}
+
+func (r *fakeOutboxRepo) Create(tx *gorm.DB, event *models.OutboxEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *fakeOutboxRepo) GetByEventID(eventID string) (*models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if event.EventID == eventID {
+			stored := *event
+			return &stored, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeOutboxRepo) ListByAggregate(aggregateType string, aggregateID uint) ([]*models.OutboxEvent, error) {
+	return nil, nil
+}
+
+func (r *fakeOutboxRepo) ClaimBatch(limit int, visibilityTimeout time.Duration) ([]*models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var claimed []*models.OutboxEvent
+	for _, event := range r.events {
+		if len(claimed) == limit {
+			break
+		}
+		if event.Status == models.OutboxPending && !event.NextAttemptAt.After(now) {
+			event.Attempts++
+			event.NextAttemptAt = now.Add(visibilityTimeout)
+			copied := *event
+			claimed = append(claimed, &copied)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *fakeOutboxRepo) MarkPublished(event *models.OutboxEvent) error {
+	return r.update(event.EventID, func(stored *models.OutboxEvent) {
+		stored.Status = models.OutboxPublished
+	})
+}
+
+func (r *fakeOutboxRepo) Reschedule(event *models.OutboxEvent, nextAttemptAt time.Time, lastError string) error {
+	return r.update(event.EventID, func(stored *models.OutboxEvent) {
+		stored.NextAttemptAt = nextAttemptAt
+		stored.LastError = lastError
+	})
+}
+
+func (r *fakeOutboxRepo) update(eventID string, apply func(*models.OutboxEvent)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, event := range r.events {
+		if event.EventID == eventID {
+			apply(event)
+		}
+	}
+	return nil
+}
+
+// flakySink fails the first failures publications.
+type flakySink struct {
+	mu        sync.Mutex
+	failures  int
+	published []string
+}
+
+func (s *flakySink) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, event.EventID)
+	return nil
+}
+
+func newOutboxEvent(id string) *models.OutboxEvent {
+	return &models.OutboxEvent{EventID: id, Status: models.OutboxPending, NextAttemptAt: time.Now()}
+}
+
+func TestOutboxRelayPublishesInOrder(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	_ = repo.Create(nil, newOutboxEvent("evt_1"))
+	_ = repo.Create(nil, newOutboxEvent("evt_2"))
+	_ = repo.Create(nil, newOutboxEvent("evt_3"))
+	sink := &flakySink{}
+
+	opts := worker.DefaultOutboxOptions()
+	opts.BatchSize = 2
+	relay := worker.NewOutboxRelay(opts, repo, sink)
+
+	assert.Equal(t, 2, relay.RunOnce(context.Background()))
+	assert.Equal(t, 1, relay.RunOnce(context.Background()))
+	assert.Equal(t, 0, relay.RunOnce(context.Background()), "published events should not be claimed again")
+	assert.Equal(t, []string{"evt_1", "evt_2", "evt_3"}, sink.published)
+}
+
+func TestOutboxRelayRetriesFailedEvents(t *testing.T) {
+	repo := &fakeOutboxRepo{}
+	_ = repo.Create(nil, newOutboxEvent("evt_1"))
+	sink := &flakySink{failures: 2}
+
+	opts := worker.DefaultOutboxOptions()
+	opts.PollInterval = time.Millisecond
+	opts.BaseBackoff = time.Millisecond
+	opts.MaxBackoff = 5 * time.Millisecond
+	relay := worker.NewOutboxRelay(opts, repo, sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	relay.Start(ctx)
+	assert.Eventually(t, func() bool {
+		event, _ := repo.GetByEventID("evt_1")
+		return event.Status == models.OutboxPublished
+	}, time.Second, time.Millisecond)
+	cancel()
+	relay.Wait()
+
+	event, _ := repo.GetByEventID("evt_1")
+	assert.Equal(t, 3, event.Attempts)
+	assert.Equal(t, "sink unavailable", event.LastError, "the last error is kept for inspection")
+	assert.Equal(t, []string{"evt_1"}, sink.published)
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+type Options struct {
+	Concurrency       int           // number of workers claiming jobs in parallel
+	PollInterval      time.Duration // how long an idle worker sleeps before looking for jobs again
+	MaxAttempts       int           // a job is dead-lettered after this many failed attempts
+	BaseBackoff       time.Duration // delay before the first retry, doubled for every further attempt
+	MaxBackoff        time.Duration // upper bound of the retry delay
+	VisibilityTimeout time.Duration // a running job is claimed again if its worker did not report back in time
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Concurrency:       4,
+		PollInterval:      500 * time.Millisecond,
+		MaxAttempts:       5,
+		BaseBackoff:       1 * time.Second,
+		MaxBackoff:        1 * time.Minute,
+		VisibilityTimeout: 1 * time.Minute,
+	}
+}
+
+// PaymentExecutor is the part of services.PaymentService the workers need.
+type PaymentExecutor interface {
+	ExecutePayment(ctx context.Context, paymentID uint) error
+	FailPayment(paymentID uint, reason string) error
+}
+
+// PaymentWorkerPool drives every pending payment to a terminal status.
+// Workers claim jobs from the payment_jobs table and execute them through the PaymentExecutor.
+// A failed attempt is retried with exponential backoff, after MaxAttempts the job is dead-lettered
+// and its payment is marked failed.
+type PaymentWorkerPool struct {
+	logger         logger.Logger
+	opts           Options
+	jobRepo        repositories.PaymentJobRepository
+	paymentService PaymentExecutor
+	wg             sync.WaitGroup
+}
+
+func NewPaymentWorkerPool(
+	opts Options,
+	jobRepo repositories.PaymentJobRepository,
+	paymentService PaymentExecutor,
+) *PaymentWorkerPool {
+	return &PaymentWorkerPool{
+		logger:         logger.Logger{},
+		opts:           opts,
+		jobRepo:        jobRepo,
+		paymentService: paymentService,
+	}
+}
+
+// Start launches the workers, they stop when ctx is done. Use Wait to wait for them to finish.
+func (p *PaymentWorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.opts.Concurrency; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx)
+		}()
+	}
+}
+
+// Wait blocks until all workers stopped, a job in progress is finished first.
+func (p *PaymentWorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+func (p *PaymentWorkerPool) run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if p.RunOnce(ctx) {
+			// keep draining while there is work
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.opts.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims and handles a single job, it reports whether a job was found.
+func (p *PaymentWorkerPool) RunOnce(ctx context.Context) bool {
+	job, err := p.jobRepo.ClaimNext(p.opts.VisibilityTimeout)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			p.logger.Error(err, "Failed to claim payment job")
+		}
+		return false
+	}
+
+	p.handle(ctx, job)
+	return true
+}
+
+func (p *PaymentWorkerPool) handle(ctx context.Context, job *models.PaymentJob) {
+	execErr := p.paymentService.ExecutePayment(ctx, job.PaymentID)
+	if execErr == nil {
+		if err := p.jobRepo.MarkSucceeded(job); err != nil {
+			p.logger.Error(err, "Failed to mark payment job succeeded")
+		}
+		return
+	}
+
+	if job.Attempts < p.opts.MaxAttempts {
+		runAt := time.Now().Add(backoff(p.opts.BaseBackoff, p.opts.MaxBackoff, job.Attempts))
+		if err := p.jobRepo.Reschedule(job, runAt, execErr.Error()); err != nil {
+			p.logger.Error(err, "Failed to reschedule payment job")
+		}
+		return
+	}
+
+	// dead letter: give up, and make sure the payment does not stay pending forever.
+	// The job is marked dead first, a worker that lost the job to another worker leaves the payment alone.
+	if err := p.jobRepo.MarkDead(job, execErr.Error()); err != nil {
+		p.logger.Error(err, "Failed to mark payment job dead")
+		return
+	}
+
+	reason := fmt.Sprintf("processing gave up after %d attempts: %s", job.Attempts, execErr.Error())
+	if err := p.paymentService.FailPayment(job.PaymentID, reason); err != nil {
+		// the payment is still pending (e.g. it is being captured), queue its job again with fresh attempts
+		p.logger.Error(err, "Failed to fail payment of dead payment job")
+		if err := p.jobRepo.Resume(job.PaymentID); err != nil {
+			p.logger.Error(err, "Failed to resume payment job")
+		}
+	}
+}
+
+// backoff returns baseDelay * 2^(attempts-1), capped at maxDelay.
+func backoff(baseDelay, maxDelay time.Duration, attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(delay, maxDelay)
+}
@@ -0,0 +1,176 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeJobRepo is an in-memory PaymentJobRepository that hands out a single job.
+type fakeJobRepo struct {
+	mu  sync.Mutex
+	job *models.PaymentJob
+}
+
+func (r *fakeJobRepo) Enqueue(tx *gorm.DB, job *models.PaymentJob) error {
+	r.job = job
+	return nil
+}
+
+func (r *fakeJobRepo) GetByPaymentID(paymentID uint) (*models.PaymentJob, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *fakeJobRepo) Resume(paymentID uint) error {
+	return nil
+}
+
+func (r *fakeJobRepo) ClaimNext(visibilityTimeout time.Duration) (*models.PaymentJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.job == nil || r.job.Status != models.JobQueued || r.job.RunAt.After(time.Now()) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	r.claim()
+	claimed := *r.job
+	return &claimed, nil
+}
+
+func (r *fakeJobRepo) claim() {
+	now := time.Now()
+	r.job.Status = models.JobRunning
+	r.job.Attempts++
+	r.job.LockedAt = &now
+}
+
+// reclaim lets another worker claim the running job, as after the visibility timeout
+func (r *fakeJobRepo) reclaim() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.claim()
+}
+
+func (r *fakeJobRepo) MarkSucceeded(job *models.PaymentJob) error {
+	return r.update(job, models.JobSucceeded, job.RunAt, job.LastError)
+}
+
+func (r *fakeJobRepo) Reschedule(job *models.PaymentJob, runAt time.Time, lastError string) error {
+	return r.update(job, models.JobQueued, runAt, lastError)
+}
+
+func (r *fakeJobRepo) MarkDead(job *models.PaymentJob, lastError string) error {
+	return r.update(job, models.JobDead, job.RunAt, lastError)
+}
+
+func (r *fakeJobRepo) update(job *models.PaymentJob, status models.PaymentJobStatus, runAt time.Time, lastError string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.job.Status != models.JobRunning || !r.job.LockedAt.Equal(*job.LockedAt) {
+		return repositories.ErrJobNotOwned
+	}
+	r.job.Status = status
+	r.job.RunAt = runAt
+	r.job.LastError = lastError
+	return nil
+}
+
+func (r *fakeJobRepo) snapshot() models.PaymentJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.job
+}
+
+// fakeExecutor fails the first failures executions.
+type fakeExecutor struct {
+	failures   int
+	executions int
+	failReason string
+	onExecute  func()
+}
+
+func (e *fakeExecutor) ExecutePayment(ctx context.Context, paymentID uint) error {
+	if e.onExecute != nil {
+		e.onExecute()
+	}
+	e.executions++
+	if e.executions <= e.failures {
+		return errors.New("processor unavailable")
+	}
+	return nil
+}
+
+func (e *fakeExecutor) FailPayment(paymentID uint, reason string) error {
+	e.failReason = reason
+	return nil
+}
+
+func newPool(repo *fakeJobRepo, executor *fakeExecutor) *worker.PaymentWorkerPool {
+	opts := worker.DefaultOptions()
+	opts.MaxAttempts = 3
+	opts.BaseBackoff = 0
+	return worker.NewPaymentWorkerPool(opts, repo, executor)
+}
+
+func TestPaymentWorkerRetriesUntilSuccess(t *testing.T) {
+	repo := &fakeJobRepo{job: &models.PaymentJob{PaymentID: 1, Status: models.JobQueued, RunAt: time.Now()}}
+	executor := &fakeExecutor{failures: 2}
+	pool := newPool(repo, executor)
+
+	for pool.RunOnce(context.Background()) {
+	}
+
+	job := repo.snapshot()
+	assert.Equal(t, models.JobSucceeded, job.Status)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Empty(t, executor.failReason, "payment should not be failed")
+}
+
+func TestPaymentWorkerDeadLettersAfterMaxAttempts(t *testing.T) {
+	repo := &fakeJobRepo{job: &models.PaymentJob{PaymentID: 1, Status: models.JobQueued, RunAt: time.Now()}}
+	executor := &fakeExecutor{failures: 10}
+	pool := newPool(repo, executor)
+
+	for pool.RunOnce(context.Background()) {
+	}
+
+	job := repo.snapshot()
+	assert.Equal(t, models.JobDead, job.Status)
+	assert.Equal(t, 3, job.Attempts)
+	assert.Equal(t, "processor unavailable", job.LastError)
+	assert.Contains(t, executor.failReason, "gave up after 3 attempts", "payment should be failed when the job is dead-lettered")
+}
+
+func TestPaymentWorkerBacksOffBetweenAttempts(t *testing.T) {
+	repo := &fakeJobRepo{job: &models.PaymentJob{PaymentID: 1, Status: models.JobQueued, RunAt: time.Now()}}
+	executor := &fakeExecutor{failures: 10}
+	pool := worker.NewPaymentWorkerPool(worker.DefaultOptions(), repo, executor)
+
+	assert.True(t, pool.RunOnce(context.Background()))
+	job := repo.snapshot()
+	assert.Equal(t, models.JobQueued, job.Status)
+	assert.WithinDuration(t, time.Now().Add(worker.DefaultOptions().BaseBackoff), job.RunAt, 100*time.Millisecond)
+	assert.False(t, pool.RunOnce(context.Background()), "job should not be due before its backoff is over")
+}
+
+func TestPaymentWorkerThatLostTheJobLeavesThePaymentAlone(t *testing.T) {
+	repo := &fakeJobRepo{job: &models.PaymentJob{PaymentID: 1, Status: models.JobQueued, Attempts: 2, RunAt: time.Now()}}
+	executor := &fakeExecutor{failures: 10}
+	// the execution outlives the visibility timeout and another worker claims the job meanwhile
+	executor.onExecute = repo.reclaim
+	pool := newPool(repo, executor)
+
+	assert.True(t, pool.RunOnce(context.Background()))
+
+	job := repo.snapshot()
+	assert.Equal(t, models.JobRunning, job.Status, "the job should stay with the worker that claimed it again")
+	assert.Empty(t, executor.failReason, "a worker that lost the job should not fail the payment")
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/utils/logger"
+)
+
+// WalletReconciler is the part of services.LedgerService the reconciliation needs.
+type WalletReconciler interface {
+	Reconcile() ([]*models.WalletReconciliation, error)
+}
+
+// StartReconciliation checks every wallet against the ledger right away and then every interval, until ctx is done.
+func StartReconciliation(ctx context.Context, interval time.Duration, reconciler WalletReconciler) {
+	log := logger.Logger{}
+	reconcile := func() {
+		mismatches, err := reconciler.Reconcile()
+		if err != nil {
+			log.Error(err, "Failed to reconcile wallets")
+			return
+		}
+		if len(mismatches) > 0 {
+			log.Info(fmt.Sprintf("[Reconciliation] %d wallets differ from the ledger", len(mismatches)))
+		}
+	}
+
+	go func() {
+		reconcile()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reconcile()
+			}
+		}
+	}()
+}
@@ -0,0 +1,165 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+
+	"gorm.io/gorm"
+)
+
+type RecoveryOptions struct {
+	Interval         time.Duration // how often the sweep runs after the one on boot
+	PendingThreshold time.Duration // a payment pending for longer than this is considered stuck
+	FailAfter        time.Duration // a stuck payment pending for longer than this is failed instead of resumed
+	BatchSize        int           // maximum number of payments looked at per sweep
+}
+
+func DefaultRecoveryOptions() RecoveryOptions {
+	return RecoveryOptions{
+		Interval:         5 * time.Minute,
+		PendingThreshold: 10 * time.Minute,
+		FailAfter:        24 * time.Hour,
+		BatchSize:        100,
+	}
+}
+
+// RecoverySweeper finds payments stuck in pending, e.g. after a crash or a deploy, and drives them forward.
+// A stuck payment without an active job is resumed by queueing its job again, and a payment that has been
+// pending for longer than FailAfter is marked failed. Payments whose job is still queued or running are left to
+// their job. Every decision is recorded in payment_recovery_audits.
+// All actions are idempotent, so sweeps of several replicas may overlap.
+type RecoverySweeper struct {
+	logger         logger.Logger
+	opts           RecoveryOptions
+	paymentRepo    repositories.PaymentRepository
+	jobRepo        repositories.PaymentJobRepository
+	auditRepo      repositories.PaymentRecoveryAuditRepository
+	paymentService PaymentExecutor
+}
+
+func NewRecoverySweeper(
+	opts RecoveryOptions,
+	paymentRepo repositories.PaymentRepository,
+	jobRepo repositories.PaymentJobRepository,
+	auditRepo repositories.PaymentRecoveryAuditRepository,
+	paymentService PaymentExecutor,
+) *RecoverySweeper {
+	return &RecoverySweeper{
+		logger:         logger.Logger{},
+		opts:           opts,
+		paymentRepo:    paymentRepo,
+		jobRepo:        jobRepo,
+		auditRepo:      auditRepo,
+		paymentService: paymentService,
+	}
+}
+
+// Start runs a sweep right away and then every Interval, until ctx is done.
+func (r *RecoverySweeper) Start(ctx context.Context) {
+	go func() {
+		r.sweepAndLog()
+
+		ticker := time.NewTicker(r.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.sweepAndLog()
+			}
+		}
+	}()
+}
+
+func (r *RecoverySweeper) sweepAndLog() {
+	audits, err := r.Sweep()
+	if err != nil {
+		r.logger.Error(err, "Failed to sweep pending payments")
+	}
+	if len(audits) > 0 {
+		r.logger.Info(fmt.Sprintf("[Recovery] Swept %d stuck pending payments", len(audits)))
+	}
+}
+
+// Sweep looks at one batch of stuck pending payments and returns the recorded decisions.
+func (r *RecoverySweeper) Sweep() ([]*models.PaymentRecoveryAudit, error) {
+	now := time.Now()
+	payments, err := r.paymentRepo.ListPendingCreatedBefore(now.Add(-r.opts.PendingThreshold), r.opts.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var audits []*models.PaymentRecoveryAudit
+	var errs []error
+	for _, payment := range payments {
+		audit, err := r.recover(payment, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", payment.ID, err))
+			continue
+		}
+		if audit == nil {
+			continue
+		}
+
+		if err := r.auditRepo.Create(audit); err != nil {
+			errs = append(errs, fmt.Errorf("payment %d: %w", payment.ID, err))
+			continue
+		}
+		audits = append(audits, audit)
+	}
+
+	return audits, errors.Join(errs...)
+}
+
+// recover decides what to do with a stuck payment and returns the decision, or nil if nothing was done.
+func (r *RecoverySweeper) recover(payment *models.Payment, now time.Time) (*models.PaymentRecoveryAudit, error) {
+	audit := &models.PaymentRecoveryAudit{
+		PaymentID:     payment.ID,
+		TransactionID: payment.TransactionID,
+		PendingSince:  payment.CreatedAt,
+	}
+	age := now.Sub(payment.CreatedAt).Round(time.Second)
+
+	if age > r.opts.FailAfter {
+		reason := fmt.Sprintf("pending for %s, longer than the recovery limit of %s", age, r.opts.FailAfter)
+		err := r.paymentService.FailPayment(payment.ID, reason)
+		if err == nil {
+			audit.Action = models.RecoveryFailed
+			audit.Reason = reason
+			return audit, nil
+		}
+		// the charge may already be captured, the payment is resumed instead so that its capture is retried
+		if !errors.Is(err, models.ErrPaymentCapturing) {
+			return nil, err
+		}
+	}
+
+	job, err := r.jobRepo.GetByPaymentID(payment.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// the job became active after the payment was listed, e.g. another replica resumed it
+	if job != nil && (job.Status == models.JobQueued || job.Status == models.JobRunning) {
+		return nil, nil
+	}
+
+	if err := r.jobRepo.Resume(payment.ID); err != nil {
+		return nil, err
+	}
+	audit.Action = models.RecoveryResumed
+	if job == nil {
+		audit.Reason = fmt.Sprintf("pending for %s without a job, job queued", age)
+	} else {
+		audit.Reason = fmt.Sprintf("pending for %s with a %s job, job queued again", age, job.Status)
+	}
+	return audit, nil
+}
@@ -0,0 +1,186 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/repositories"
+	"payment-service/internal/utils/logger"
+	"payment-service/internal/webhook"
+
+	"gorm.io/gorm"
+)
+
+type WebhookOptions struct {
+	Concurrency       int           // number of dispatchers sending deliveries in parallel
+	PollInterval      time.Duration // how long an idle dispatcher sleeps before looking for deliveries again
+	MaxAttempts       int           // a delivery is failed after this many unsuccessful attempts
+	BaseBackoff       time.Duration // delay before the first retry, doubled for every further attempt
+	MaxBackoff        time.Duration // upper bound of the retry delay
+	RequestTimeout    time.Duration // timeout of a single HTTP request to an endpoint
+	VisibilityTimeout time.Duration // a claimed delivery is claimed again if its dispatcher did not report back in time
+}
+
+func DefaultWebhookOptions() WebhookOptions {
+	return WebhookOptions{
+		Concurrency:       2,
+		PollInterval:      1 * time.Second,
+		MaxAttempts:       8,
+		BaseBackoff:       10 * time.Second,
+		MaxBackoff:        1 * time.Hour,
+		RequestTimeout:    10 * time.Second,
+		VisibilityTimeout: 1 * time.Minute,
+	}
+}
+
+// WebhookDispatcher sends the queued webhook deliveries to the merchant endpoints.
+// Every request is signed with the secret of the endpoint and recorded in the delivery log.
+// A delivery that is not answered with 2xx is retried with exponential backoff, after MaxAttempts
+// it is failed and waits for a manual redelivery.
+type WebhookDispatcher struct {
+	logger       logger.Logger
+	opts         WebhookOptions
+	endpointRepo repositories.WebhookEndpointRepository
+	deliveryRepo repositories.WebhookDeliveryRepository
+	client       *http.Client
+	wg           sync.WaitGroup
+}
+
+func NewWebhookDispatcher(
+	opts WebhookOptions,
+	endpointRepo repositories.WebhookEndpointRepository,
+	deliveryRepo repositories.WebhookDeliveryRepository,
+) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		logger:       logger.Logger{},
+		opts:         opts,
+		endpointRepo: endpointRepo,
+		deliveryRepo: deliveryRepo,
+		client:       &http.Client{Timeout: opts.RequestTimeout},
+	}
+}
+
+// Start launches the dispatchers, they stop when ctx is done. Use Wait to wait for them to finish.
+func (d *WebhookDispatcher) Start(ctx context.Context) {
+	for i := 0; i < d.opts.Concurrency; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.run(ctx)
+		}()
+	}
+}
+
+// Wait blocks until all dispatchers stopped, a delivery in progress is finished first.
+func (d *WebhookDispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *WebhookDispatcher) run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if d.RunOnce(ctx) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.opts.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims and sends a single delivery, it reports whether a delivery was found.
+func (d *WebhookDispatcher) RunOnce(ctx context.Context) bool {
+	delivery, err := d.deliveryRepo.ClaimNext(d.opts.VisibilityTimeout)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			d.logger.Error(err, "Failed to claim webhook delivery")
+		}
+		return false
+	}
+
+	d.deliver(ctx, delivery)
+	return true
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	attempt := &models.WebhookDeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    delivery.Attempts,
+	}
+
+	endpoint, err := d.endpointRepo.GetByID(delivery.EndpointID)
+	switch {
+	case err != nil:
+		attempt.Error = fmt.Sprintf("failed to get endpoint: %s", err)
+	case !endpoint.Active:
+		// nothing to retry, the merchant removed the endpoint
+		attempt.Error = "endpoint is inactive"
+		delivery.Attempts = d.opts.MaxAttempts
+	default:
+		started := time.Now()
+		attempt.StatusCode, err = d.send(ctx, endpoint, delivery)
+		attempt.DurationMs = time.Since(started).Milliseconds()
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	}
+
+	now := time.Now()
+	delivery.LastStatusCode = attempt.StatusCode
+	delivery.LastError = attempt.Error
+	switch {
+	case attempt.Succeeded():
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	case delivery.Attempts < d.opts.MaxAttempts:
+		delivery.Status = models.DeliveryPending
+		delivery.NextAttemptAt = now.Add(backoff(d.opts.BaseBackoff, d.opts.MaxBackoff, delivery.Attempts))
+	default:
+		delivery.Status = models.DeliveryFailed
+	}
+
+	if err := d.deliveryRepo.RecordAttempt(delivery, attempt); err != nil {
+		d.logger.Error(err, "Failed to record webhook delivery attempt")
+	}
+}
+
+// send posts the event to the endpoint and returns the status code of the answer.
+func (d *WebhookDispatcher) send(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhook.IDHeader, delivery.EventID)
+	req.Header.Set(webhook.TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhook.SignatureHeader, webhook.Sign(endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,243 @@
+package worker_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"payment-service/internal/models"
+	"payment-service/internal/webhook"
+	"payment-service/internal/worker"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakeEndpointRepo is an in-memory WebhookEndpointRepository with a single endpoint.
+type fakeEndpointRepo struct {
+	endpoint *models.WebhookEndpoint
+}
+
+func (r *fakeEndpointRepo) Create(endpoint *models.WebhookEndpoint) error {
+	r.endpoint = endpoint
+	return nil
+}
+
+func (r *fakeEndpointRepo) GetByID(id uint) (*models.WebhookEndpoint, error) {
+	if r.endpoint == nil || r.endpoint.ID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	endpoint := *r.endpoint
+	return &endpoint, nil
+}
+
+func (r *fakeEndpointRepo) GetAll() ([]*models.WebhookEndpoint, error) {
+	return []*models.WebhookEndpoint{r.endpoint}, nil
+}
+
+func (r *fakeEndpointRepo) ListActive() ([]*models.WebhookEndpoint, error) {
+	return r.GetAll()
+}
+
+func (r *fakeEndpointRepo) Deactivate(id uint) error {
+	r.endpoint.Active = false
+	return nil
+}
+
+// fakeDeliveryRepo is an in-memory WebhookDeliveryRepository that hands out a single delivery.
+type fakeDeliveryRepo struct {
+	mu       sync.Mutex
+	delivery *models.WebhookDelivery
+	attempts []models.WebhookDeliveryAttempt
+}
+
+func (r *fakeDeliveryRepo) CreateIfAbsent(deliveries []*models.WebhookDelivery) error {
+	r.delivery = deliveries[0]
+	return nil
+}
+
+func (r *fakeDeliveryRepo) GetByID(id uint) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery := *r.delivery
+	return &delivery, nil
+}
+
+func (r *fakeDeliveryRepo) ListByEndpointID(endpointID uint) ([]*models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (r *fakeDeliveryRepo) ClaimNext(visibilityTimeout time.Duration) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.delivery.Status != models.DeliveryPending || r.delivery.NextAttemptAt.After(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	r.delivery.Attempts++
+	r.delivery.NextAttemptAt = now.Add(visibilityTimeout)
+	claimed := *r.delivery
+	return &claimed, nil
+}
+
+func (r *fakeDeliveryRepo) RecordAttempt(delivery *models.WebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts = append(r.attempts, *attempt)
+	stored := *delivery
+	r.delivery = &stored
+	return nil
+}
+
+func (r *fakeDeliveryRepo) Redeliver(id uint) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	r.delivery.Status = models.DeliveryPending
+	r.delivery.Attempts = 0
+	r.delivery.NextAttemptAt = time.Now()
+	r.mu.Unlock()
+	return r.GetByID(id)
+}
+
+func (r *fakeDeliveryRepo) RedeliverFailed(endpointID uint) (int64, error) {
+	return 0, nil
+}
+
+// receiver is a local merchant endpoint that answers with the scripted status codes, then 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func newDispatcher(t *testing.T, recv *receiver, maxAttempts int) (*worker.WebhookDispatcher, *fakeEndpointRepo, *fakeDeliveryRepo) {
+	server := httptest.NewServer(recv)
+	t.Cleanup(server.Close)
+
+	endpointRepo := &fakeEndpointRepo{endpoint: &models.WebhookEndpoint{ID: 1, URL: server.URL, Secret: "whsec_test", Active: true}}
+	deliveryRepo := &fakeDeliveryRepo{delivery: &models.WebhookDelivery{
+		ID:            1,
+		EndpointID:    1,
+		EventID:       "evt_payment_1_completed",
+		EventType:     models.EventPaymentCompleted,
+		PaymentID:     1,
+		Payload:       []byte(`{"id":"evt_payment_1_completed","type":"payment.completed"}`),
+		Status:        models.DeliveryPending,
+		NextAttemptAt: time.Now(),
+	}}
+
+	opts := worker.DefaultWebhookOptions()
+	opts.MaxAttempts = maxAttempts
+	opts.BaseBackoff = time.Millisecond
+	opts.MaxBackoff = time.Millisecond
+	opts.RequestTimeout = time.Second
+	return worker.NewWebhookDispatcher(opts, endpointRepo, deliveryRepo), endpointRepo, deliveryRepo
+}
+
+// drain runs the dispatcher until the delivery is no longer pending.
+func drain(d *worker.WebhookDispatcher, repo *fakeDeliveryRepo) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if delivery, _ := repo.GetByID(1); delivery.Status != models.DeliveryPending {
+			return
+		}
+		if !d.RunOnce(context.Background()) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestWebhookDispatcherDeliversSignedEvent(t *testing.T) {
+	recv := &receiver{}
+	dispatcher, endpointRepo, deliveryRepo := newDispatcher(t, recv, 3)
+
+	drain(dispatcher, deliveryRepo)
+
+	delivery, _ := deliveryRepo.GetByID(1)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.NotNil(t, delivery.DeliveredAt)
+	assert.Equal(t, 1, recv.count())
+
+	req := recv.requests[0]
+	assert.Equal(t, "evt_payment_1_completed", req.Header.Get(webhook.IDHeader))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	err := webhook.Verify(endpointRepo.endpoint.Secret, req.Header.Get(webhook.SignatureHeader), req.Header.Get(webhook.TimestampHeader),
+		recv.bodies[0], time.Now(), time.Minute)
+	assert.NoError(t, err, "receiver should be able to verify the signature")
+}
+
+func TestWebhookDispatcherRetriesWithBackoff(t *testing.T) {
+	recv := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	dispatcher, _, deliveryRepo := newDispatcher(t, recv, 3)
+
+	drain(dispatcher, deliveryRepo)
+
+	delivery, _ := deliveryRepo.GetByID(1)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Equal(t, 3, recv.count())
+
+	// every request is in the delivery log
+	assert.Len(t, deliveryRepo.attempts, 3)
+	assert.Equal(t, http.StatusInternalServerError, deliveryRepo.attempts[0].StatusCode)
+	assert.Equal(t, "endpoint responded 500", deliveryRepo.attempts[0].Error)
+	assert.Equal(t, http.StatusOK, deliveryRepo.attempts[2].StatusCode)
+	assert.True(t, deliveryRepo.attempts[2].Succeeded())
+}
+
+func TestWebhookDispatcherGivesUpAndRedelivers(t *testing.T) {
+	recv := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError}}
+	dispatcher, _, deliveryRepo := newDispatcher(t, recv, 2)
+
+	drain(dispatcher, deliveryRepo)
+
+	delivery, _ := deliveryRepo.GetByID(1)
+	assert.Equal(t, models.DeliveryFailed, delivery.Status, "delivery should fail after the last attempt")
+	assert.Equal(t, http.StatusInternalServerError, delivery.LastStatusCode)
+	assert.False(t, dispatcher.RunOnce(context.Background()), "a failed delivery should not be claimed again")
+
+	_, _ = deliveryRepo.Redeliver(1)
+	drain(dispatcher, deliveryRepo)
+
+	delivery, _ = deliveryRepo.GetByID(1)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 3, recv.count())
+	assert.Len(t, deliveryRepo.attempts, 3, "redelivery should keep the log of the earlier attempts")
+}
+
+func TestWebhookDispatcherInactiveEndpoint(t *testing.T) {
+	recv := &receiver{}
+	dispatcher, endpointRepo, deliveryRepo := newDispatcher(t, recv, 5)
+	_ = endpointRepo.Deactivate(1)
+
+	drain(dispatcher, deliveryRepo)
+
+	delivery, _ := deliveryRepo.GetByID(1)
+	assert.Equal(t, models.DeliveryFailed, delivery.Status)
+	assert.Equal(t, "endpoint is inactive", delivery.LastError)
+	assert.Zero(t, recv.count())
+}